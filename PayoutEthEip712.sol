@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.7;
+
+import "@openzeppelin/contracts/token/ERC20/IERC20.sol";
+
+/**
+* @dev Same as PayoutEth, but the withdraw signature is EIP-712 typed data. The domain contains the chain id and the
+* contract address, so a signature cannot be replayed on another chain or contract. The recipient is part of the signed
+* data, so a signature cannot be front-run to another address.
+*/
+contract PayoutEthEip712 {
+
+    bytes32 private constant DOMAIN_TYPEHASH =
+        keccak256("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)");
+
+    bytes32 private constant WITHDRAW_TYPEHASH =
+        keccak256("Withdraw(address dev,bytes32 userId,uint256 totalPayOut)");
+
+    /**
+    * @dev Maps each userId to its current already payed out amount. The userId never changes
+    */
+    mapping(bytes32 => uint256) public payedOut;
+
+    /**
+    * @dev The contract owner
+    */
+    address public owner;
+
+    modifier onlyOwner() {
+        require(msg.sender == owner, "No authorization");
+        _;
+    }
+
+    constructor () {
+        owner = payable(msg.sender);
+    }
+
+    receive() external payable {
+    }
+
+    /**
+    * @dev The domain separator, calculated on every call, as the chain id can change after a fork.
+    */
+    function domainSeparator() public view returns (bytes32) {
+        return keccak256(abi.encode(DOMAIN_TYPEHASH, keccak256("PayoutEth"), keccak256("1"), block.chainid, address(this)));
+    }
+
+    /**
+    * @dev Send back from contract in case something is wrong. This should rarely happen
+    */
+    function sndRecoverEth(address payable receiver, uint256 amount) external onlyOwner() {
+        receiver.transfer(amount);
+    }
+
+    /**
+    * @dev Send back from contract in case something is wrong. This should never happen
+    */
+    function sndRecoverToken(address receiver, address contractAddress, uint256 amount) external onlyOwner() {
+        IERC20(contractAddress).transfer(receiver, amount);
+    }
+
+    /**
+    * @dev Changes the owner of this contract.
+    */
+    function changeOwner(address payable newOwner) external onlyOwner() {
+        owner = newOwner;
+    }
+
+    /**
+    * @dev Gets the tea for the provided address.
+    */
+    function getPayedOut(bytes32 userId) external view returns (uint256) {
+        return payedOut[userId];
+    }
+
+    /**
+    * @dev Gets the tea for the provided address.
+    */
+    function getClaimableAmount(bytes32 userId, uint256 totalPayOut) external view returns (uint256) {
+        return totalPayOut - payedOut[userId];
+    }
+
+    /**
+    * @dev Withdraws the earned amount. The signature has to be created by the contract owner over the EIP-712 typed
+    * data Withdraw(dev, userId, totalPayOut).
+    *
+    * @param dev The address to withdraw to.
+    * @param userId The user id that never changes
+    * @param totalPayOut The total amount that the user earned.
+    * @param v The recovery byte of the signature.
+    * @param r The r value of the signature.
+    * @param s The s value of the signature.
+    */
+    function withdraw(address payable dev, bytes32 userId, uint256 totalPayOut, uint8 v, bytes32 r, bytes32 s) external {
+        require(totalPayOut > payedOut[userId], "No new funds to be withdrawn");
+        bytes32 structHash = keccak256(abi.encode(WITHDRAW_TYPEHASH, dev, userId, totalPayOut));
+        bytes32 digest = keccak256(abi.encodePacked("\x19\x01", domainSeparator(), structHash));
+        require(ecrecover(digest, v, r, s) == owner, "Signature no match");
+        uint256 old = payedOut[userId];
+        payedOut[userId] = totalPayOut;
+        // transfer reverts transaction if not successful.
+        dev.transfer(totalPayOut - old);
+    }
+
+}
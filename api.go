@@ -1,32 +1,56 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"math/big"
 	"net/http"
 	"strconv"
-	"time"
 )
 
+// Config has the ETH values at the top level as before, and the values of all enabled chains, including every EVM
+// chain, in Chains
 type Config struct {
-	PayoutContractAddress string `json:"payoutContractAddress"`
-	ChainId               int64  `json:"chainId"`
-	Env                   string `json:"env"`
+	PayoutContractAddress string        `json:"payoutContractAddress"`
+	ChainId               int64         `json:"chainId"`
+	Env                   string        `json:"env"`
+	SignMode              string        `json:"signMode"`
+	Chains                []ChainConfig `json:"chains"`
 }
 
+// PayoutRequest2 is the request to sign a payout. Address is the verified payout address of the developer, it is
+// part of the signed message in all sign modes except legacy. ChainId selects the EVM chain, the ETH chain if 0.
 type PayoutRequest2 struct {
-	UserId uuid.UUID `json:"userId"`
-	Amount *big.Int  `json:"amount"`
+	UserId  uuid.UUID      `json:"userId"`
+	Amount  *big.Int       `json:"amount"`
+	Address common.Address `json:"address"`
+	ChainId int64          `json:"chainId,omitempty"`
 }
 
-func sign(w http.ResponseWriter, r *http.Request) {
+// BatchPayoutRequest is a single entry of a batch sign call. The userId and address are kept as string, so that a
+// malformed value only fails its own entry and not the whole batch.
+type BatchPayoutRequest struct {
+	UserId  string   `json:"userId"`
+	Amount  *big.Int `json:"amount"`
+	Address string   `json:"address"`
+	ChainId int64    `json:"chainId,omitempty"`
+}
+
+// BatchSignature is the result of a single entry of a batch sign call, either a signature or an error.
+type BatchSignature struct {
+	Signature  *Signature `json:"signature,omitempty"`
+	ApprovalId *uuid.UUID `json:"approvalId,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Rule       string     `json:"rule,omitempty"`
+}
+
+func sign(w http.ResponseWriter, r *http.Request, subject string) {
 	var data PayoutRequest2
 	err := json.NewDecoder(r.Body).Decode(&data)
 	if err != nil {
@@ -34,48 +58,236 @@ func sign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	privateKey, err := crypto.HexToECDSA(opts.Ethereum.PrivateKey)
+	c, err := ethChainById(data.ChainId)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	sig, err := signAndRecord(c, data.UserId, data.Amount, data.Address, subject, false)
+	if isApprovalRequired(err) {
+		a, err := createApproval(c, data.UserId, data.Amount, data.Address, subject, err.Error())
+		if err != nil {
+			writeErr(w, http.StatusInternalServerError, "could not create approval: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		writeJson(w, PendingApproval{ApprovalId: a.Id, Status: a.Status, Rule: RuleApprovalThreshold})
+		return
+	}
+	var errPolicy *ErrPolicy
+	if errors.As(err, &errPolicy) {
+		writeRuleErr(w, errPolicy)
+		return
+	}
 	if err != nil {
-		writeErr(w, http.StatusBadRequest, "private key error %v", err)
+		writeErr(w, signErrStatus(err), "sign error %v", err)
 		return
 	}
 
-	b1, _ := data.UserId.MarshalBinary()
-	b2 := make([]byte, 32-len(b1))
-	b3 := append(b1, b2...)
-	b4 := math.U256Bytes(data.Amount)
+	writeJson(w, sig)
+}
 
-	hashRaw := crypto.Keccak256(b3, []byte{'#'}, b4)
-	signature, err := crypto.Sign(hashRaw, privateKey)
+func signBatch(w http.ResponseWriter, r *http.Request, subject string) {
+	var data []BatchPayoutRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
 	if err != nil {
-		writeErr(w, http.StatusBadRequest, "private key error %v", err)
+		writeErr(w, http.StatusBadRequest, "could not decode batch: %v", err)
 		return
 	}
 
-	//https://ethereum.stackexchange.com/questions/45580/validating-go-ethereum-key-signature-with-ecrecover
-	sig := Signature{
-		signature,
-		bytes32(hashRaw),
-		bytes32(signature[:32]),
-		bytes32(signature[32:64]),
-		uint8(int(signature[65])) + 27, // Yes add 27, weird Ethereum quirk
+	//results have the same order as the request
+	results := make([]BatchSignature, len(data))
+	for i, d := range data {
+		c, err := ethChainById(d.ChainId)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		userId, err := uuid.Parse(d.UserId)
+		if err != nil {
+			results[i].Error = fmt.Sprintf("invalid userId %v: %v", d.UserId, err)
+			continue
+		}
+		var dev common.Address
+		if d.Address != "" {
+			if !common.IsHexAddress(d.Address) {
+				results[i].Error = fmt.Sprintf("invalid address %v", d.Address)
+				continue
+			}
+			dev = common.HexToAddress(d.Address)
+		}
+		sig, err := signAndRecord(c, userId, d.Amount, dev, subject, false)
+		if isApprovalRequired(err) {
+			a, err := createApproval(c, userId, d.Amount, dev, subject, err.Error())
+			if err != nil {
+				results[i].Error = fmt.Sprintf("could not create approval: %v", err)
+				continue
+			}
+			results[i].ApprovalId = &a.Id
+			results[i].Rule = RuleApprovalThreshold
+			continue
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			var errPolicy *ErrPolicy
+			if errors.As(err, &errPolicy) {
+				results[i].Rule = errPolicy.Rule
+			}
+			continue
+		}
+		results[i].Signature = sig
 	}
 
-	writeJson(w, sig)
+	log.Printf("signed batch of %v entries", len(data))
+	writeJson(w, results)
+}
+
+// VerifyRequest contains the values passed to PayoutEth.withdraw, Address is the dev parameter. ChainId selects the
+// EVM chain, the ETH chain if 0.
+type VerifyRequest struct {
+	UserId        uuid.UUID      `json:"userId"`
+	TotalPayedOut *big.Int       `json:"totalPayedOut"`
+	Address       common.Address `json:"address"`
+	V             uint8          `json:"v"`
+	R             common.Hash    `json:"r"`
+	S             common.Hash    `json:"s"`
+	ChainId       int64          `json:"chainId,omitempty"`
+}
+
+type VerifyResponse struct {
+	Valid    bool           `json:"valid"`
+	Hash     common.Hash    `json:"hash"`
+	Digest   common.Hash    `json:"digest"`
+	Signer   common.Address `json:"signer"`
+	Owner    common.Address `json:"owner"`
+	SignMode string         `json:"signMode"`
+}
+
+// verify rebuilds the digest the contract checks in withdraw and compares the recovered signer with the contract
+// owner, so a "Signature no match" revert can be diagnosed without sending a transaction
+func verify(w http.ResponseWriter, r *http.Request) {
+	var data VerifyRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode verify request: %v", err)
+		return
+	}
+	if data.TotalPayedOut == nil || data.TotalPayedOut.Sign() < 0 {
+		writeErr(w, http.StatusBadRequest, "invalid totalPayedOut %v", data.TotalPayedOut)
+		return
+	}
+
+	c, err := ethChainById(data.ChainId)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	hashRaw, _, err := payoutHash(c, data.UserId, data.TotalPayedOut, data.Address)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "hash error %v", err)
+		return
+	}
+	digest := contractDigest(c, hashRaw)
+
+	signer, err := recoverSigner(digest, data.V, data.R, data.S)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not recover signer: %v", err)
+		return
+	}
+
+	owner, err := c.owner()
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read contract owner: %v", err)
+		return
+	}
+
+	writeJson(w, VerifyResponse{
+		Valid:    signer == owner,
+		Hash:     common.BytesToHash(hashRaw),
+		Digest:   common.BytesToHash(digest),
+		Signer:   signer,
+		Owner:    owner,
+		SignMode: c.signMode,
+	})
+}
+
+// NeoSignature contains the parameters for PayoutNeo.withdraw(account, tea, signature)
+type NeoSignature struct {
+	Address   string        `json:"address"`
+	Account   string        `json:"account"`
+	Tea       *big.Int      `json:"tea"`
+	Signature hexutil.Bytes `json:"signature"`
+	Claimable *big.Int      `json:"claimable,omitempty"`
+}
+
+type XtzSignature struct {
+	Address   string        `json:"address"`
+	Tea       *big.Int      `json:"tea"`
+	Signature string        `json:"signature"`
+	Packed    hexutil.Bytes `json:"packed"`
+	Claimable *big.Int      `json:"claimable,omitempty"`
+}
+
+// signErrStatus maps the errors of signAndRecord to the HTTP status code
+func signErrStatus(err error) int {
+	var errDecreased *ErrAmountDecreased
+	var errNoNewFunds *ErrNoNewFunds
+	if errors.As(err, &errDecreased) || errors.As(err, &errNoNewFunds) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, errContractNotVerified) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// ServerTime is the time of the server and of the latest block of every chain
+type ServerTime struct {
+	Time   string            `json:"time"`
+	Offset int               `json:"offset"`
+	Chains map[string]string `json:"chains"`
+}
+
+// TimeWarp is the result of a time warp, the chains in Failed lag behind the offset
+type TimeWarp struct {
+	Seconds int               `json:"seconds"`
+	Offset  int               `json:"offset"`
+	Warped  []string          `json:"warped"`
+	Skipped []string          `json:"skipped"`
+	Failed  map[string]string `json:"failed"`
 }
 
 func serverTime(w http.ResponseWriter, r *http.Request, email string) {
-	currentTime := timeNow()
-	writeJsonStr(w, `{"time":"`+currentTime.Format("2006-01-02 15:04:05")+`","offset":`+strconv.Itoa(secondsAdd)+`}`)
+	st := ServerTime{
+		Time:   timeNow().Format("2006-01-02 15:04:05"),
+		Offset: secondsAdd,
+		Chains: map[string]string{},
+	}
+	for _, name := range chainNames {
+		t, err := chains[name].Time()
+		if err != nil {
+			writeErr(w, chainErrStatus(err), "could not read %v block: %v", name, err)
+			return
+		}
+		st.Chains[name] = t.Format("2006-01-02 15:04:05")
+	}
+	writeJson(w, st)
 }
 
 func serverTimeEth(w http.ResponseWriter, r *http.Request, email string) {
-	header, err := ethClient.c.HeaderByNumber(context.Background(), nil)
+	c, ok := chains["eth"]
+	if !ok {
+		writeErr(w, http.StatusNotFound, "chain eth is not enabled")
+		return
+	}
+	currentTime, err := c.Time()
 	if err != nil {
-		log.Fatal(err)
+		writeErr(w, chainErrStatus(err), "could not read eth block: %v", err)
+		return
 	}
-
-	currentTime := time.Unix(int64(header.Time), 0)
 	writeJsonStr(w, `{"time":"`+currentTime.Format("2006-01-02 15:04:05")+`","offset":`+strconv.Itoa(secondsAdd)+`}`)
 }
 
@@ -93,23 +305,53 @@ func timeWarp(w http.ResponseWriter, r *http.Request, _ string) {
 	}
 
 	seconds := hours * 60 * 60
-	err = warpChain(seconds, ethClient.rpc)
-	//TODO: warp chain on in-memory NEO chain
-
-	if err != nil {
-		writeErr(w, http.StatusBadRequest, "Could not warp time: %v", m)
-		return
+	res := TimeWarp{Seconds: seconds, Warped: []string{}, Skipped: []string{}, Failed: map[string]string{}}
+	//NEO first, if it fails nothing was moved yet and the offset stays
+	if neoClient != nil {
+		warped, err := warpNeoChain(seconds)
+		if err != nil {
+			writeErr(w, neoErrStatus(err), "Could not warp NEO time: %v", err)
+			return
+		}
+		if warped {
+			res.Warped = append(res.Warped, "neo")
+		} else {
+			res.Skipped = append(res.Skipped, "neo")
+		}
+	}
+	//the chains that moved cannot be moved back, so the offset is applied even if one of them fails
+	for _, c := range ethClients {
+		err = warpChain(seconds, c.rpc)
+		if err != nil {
+			log.Printf("Could not warp %v time: %v", c.name, err)
+			res.Failed[c.name] = err.Error()
+		} else {
+			res.Warped = append(res.Warped, c.name)
+		}
 	}
 
 	secondsAdd += seconds
+	res.Offset = secondsAdd
 	log.Printf("time warp: %v", timeNow())
+	if len(res.Failed) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	writeJson(w, res)
 }
 
 func config(w http.ResponseWriter, _ *http.Request) {
 	cfg := Config{
-		PayoutContractAddress: opts.Ethereum.Contract,
-		ChainId:               ethClient.chainId.Int64(),
-		Env:                   opts.Env,
+		Env:    opts.Env,
+		Chains: []ChainConfig{},
+	}
+	if ethClient != nil {
+		cfg.PayoutContractAddress = ethClient.address.Hex()
+		cfg.ChainId = ethClient.chainId.Int64()
+		cfg.SignMode = ethClient.signMode
+	}
+	for _, name := range chainNames {
+		cfg.Chains = append(cfg.Chains, chains[name].Config())
 	}
 	writeJson(w, cfg)
 }
@@ -136,6 +378,19 @@ func writeErr(w http.ResponseWriter, code int, format string, a ...interface{})
 	}
 }
 
+// writeRuleErr writes a policy rejection, unlike writeErr the rule name is always returned
+func writeRuleErr(w http.ResponseWriter, e *ErrPolicy) {
+	log.Printf("%v", e)
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(http.StatusForbidden)
+	var err = json.NewEncoder(w).Encode(map[string]string{"error": "policy rejected", "rule": e.Rule})
+	if err != nil {
+		log.Printf("Could encode json: %v", err)
+	}
+}
+
 func writeJson(w http.ResponseWriter, obj interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	var err = json.NewEncoder(w).Encode(obj)
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalSigning  = "signing"
+	ApprovalApproved = "approved"
+	ApprovalFailed   = "failed"
+	ApprovalExpired  = "expired"
+)
+
+var bucketApprovals = []byte("approvals")
+
+// Approval is a signature request above the approval threshold. The signature is only created after
+// opts.ApprovalsRequired distinct admins approved it. NEO and Tezos requests have the Chain and Account set.
+type Approval struct {
+	Id        uuid.UUID      `json:"id"`
+	UserId    uuid.UUID      `json:"userId"`
+	Chain     string         `json:"chain,omitempty"`
+	Account   string         `json:"account,omitempty"`
+	Amount    *big.Int       `json:"amount"`
+	Address   common.Address `json:"address"`
+	ChainId   int64          `json:"chainId,omitempty"`
+	Subject   string         `json:"subject"`
+	Reason    string         `json:"reason"`
+	Approvers []string       `json:"approvers"`
+	Required  int            `json:"required"`
+	Status    string         `json:"status"`
+	Signature interface{}    `json:"signature,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+}
+
+// PendingApproval is returned instead of a signature if an approval is needed
+type PendingApproval struct {
+	ApprovalId uuid.UUID `json:"approvalId"`
+	Status     string    `json:"status"`
+	Rule       string    `json:"rule"`
+}
+
+func createApproval(c *ClientETH, userId uuid.UUID, amount *big.Int, dev common.Address, subject string, reason string) (*Approval, error) {
+	return storeApproval(&Approval{
+		UserId:  userId,
+		Amount:  amount,
+		Address: dev,
+		ChainId: c.chainId.Int64(),
+		Subject: subject,
+	}, reason)
+}
+
+// createAccountApproval creates the approval of a NEO or Tezos signature for the account
+func createAccountApproval(chain string, account string, amount *big.Int, subject string, reason string) (*Approval, error) {
+	return storeApproval(&Approval{
+		Chain:   chain,
+		Account: account,
+		Amount:  amount,
+		Subject: subject,
+	}, reason)
+}
+
+// storeApproval stores the signature request as a new pending approval
+func storeApproval(a *Approval, reason string) (*Approval, error) {
+	now := timeNow()
+	a.Id = uuid.New()
+	a.Reason = reason
+	a.Approvers = []string{}
+	a.Required = opts.ApprovalsRequired
+	a.Status = ApprovalPending
+	a.CreatedAt = now
+	a.ExpiresAt = now.Add(time.Duration(opts.ApprovalTTLHours) * time.Hour)
+	err := db.Update(func(tx *bolt.Tx) error {
+		return putApproval(tx, a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("created approval %v: %v", a.Id, reason)
+	return a, nil
+}
+
+func putApproval(tx *bolt.Tx, a *Approval) error {
+	j, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketApprovals).Put(a.Id[:], j)
+}
+
+// getApproval reads an approval and marks it as expired if a pending approval is past its expiry
+func getApproval(tx *bolt.Tx, id uuid.UUID) (*Approval, error) {
+	v := tx.Bucket(bucketApprovals).Get(id[:])
+	if v == nil {
+		return nil, fmt.Errorf("approval %v not found", id)
+	}
+	a := &Approval{}
+	if err := json.Unmarshal(v, a); err != nil {
+		return nil, err
+	}
+	if a.Status == ApprovalPending && timeNow().After(a.ExpiresAt) {
+		a.Status = ApprovalExpired
+	}
+	return a, nil
+}
+
+// approve adds the admin to the approvers. The admin that adds the last required approval creates the signature.
+func approve(id uuid.UUID, email string) (*Approval, error) {
+	var a *Approval
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		a, err = getApproval(tx, id)
+		if err != nil {
+			return err
+		}
+		if a.Status != ApprovalPending {
+			return fmt.Errorf("approval %v is %v", id, a.Status)
+		}
+		for _, approver := range a.Approvers {
+			if approver == email {
+				return fmt.Errorf("approval %v was already approved by %v", id, email)
+			}
+		}
+		a.Approvers = append(a.Approvers, email)
+		if len(a.Approvers) >= a.Required {
+			a.Status = ApprovalSigning
+		}
+		return putApproval(tx, a)
+	})
+	if err != nil || a.Status != ApprovalSigning {
+		return a, err
+	}
+	return completeApproval(a)
+}
+
+// completeApproval creates the signature of an approval in the signing state and stores the result
+func completeApproval(a *Approval) (*Approval, error) {
+	sig, err := signApproval(a)
+	if err != nil {
+		a.Status = ApprovalFailed
+		a.Error = err.Error()
+	} else {
+		a.Status = ApprovalApproved
+		a.Signature = sig
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return putApproval(tx, a)
+	})
+	log.Printf("approval %v is %v", a.Id, a.Status)
+	return a, err
+}
+
+// signApproval signs the request of the approval on its chain
+func signApproval(a *Approval) (interface{}, error) {
+	switch a.Chain {
+	case "":
+		//approvals without chain id were created for the ETH chain
+		c, err := ethChainById(a.ChainId)
+		if err != nil {
+			return nil, err
+		}
+		return signAndRecord(c, a.UserId, a.Amount, a.Address, a.Subject, true)
+	case "neo":
+		return signNeoAndRecord(a.Account, a.Amount, a.Subject, true)
+	case "xtz":
+		return xtzClient.signWithdrawAndRecord(a.Account, a.Amount, a.Subject, true)
+	default:
+		return nil, fmt.Errorf("chain %v is not supported", a.Chain)
+	}
+}
+
+// resumeApprovals completes the approvals that were left in the signing state, e.g., by a crash while the signer was
+// called. If the signature was already recorded, the same amount is signed again.
+func resumeApprovals() {
+	var signing []*Approval
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketApprovals).ForEach(func(k, _ []byte) error {
+			a, err := getApproval(tx, uuid.UUID(bytes16(k)))
+			if err != nil {
+				return err
+			}
+			if a.Status == ApprovalSigning {
+				signing = append(signing, a)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Errorf("could not read approvals: %v", err)
+		return
+	}
+	for _, a := range signing {
+		log.Printf("resuming approval %v", a.Id)
+		if _, err = completeApproval(a); err != nil {
+			log.Errorf("could not store approval %v: %v", a.Id, err)
+		}
+	}
+}
+
+func approvals(w http.ResponseWriter, _ *http.Request, _ string) {
+	result := []Approval{}
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketApprovals).ForEach(func(k, _ []byte) error {
+			a, err := getApproval(tx, uuid.UUID(bytes16(k)))
+			if err != nil {
+				return err
+			}
+			if a.Status == ApprovalPending {
+				result = append(result, *a)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read approvals: %v", err)
+		return
+	}
+	writeJson(w, result)
+}
+
+func approvalGet(w http.ResponseWriter, r *http.Request, _ string) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid approval id: %v", err)
+		return
+	}
+	var a *Approval
+	err = db.View(func(tx *bolt.Tx) error {
+		a, err = getApproval(tx, id)
+		return err
+	})
+	if err != nil {
+		writeErr(w, http.StatusNotFound, "could not read approval: %v", err)
+		return
+	}
+	writeJson(w, a)
+}
+
+func approvalPost(w http.ResponseWriter, r *http.Request, email string) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid approval id: %v", err)
+		return
+	}
+	a, err := approve(id, email)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not approve: %v", err)
+		return
+	}
+	writeJson(w, a)
+}
+
+// isApprovalRequired returns true if the error is the approval threshold rule
+func isApprovalRequired(err error) bool {
+	var errPolicy *ErrPolicy
+	return errors.As(err, &errPolicy) && errPolicy.Rule == RuleApprovalThreshold
+}
+
+func bytes16(b []byte) [16]byte {
+	var ret [16]byte
+	copy(ret[:], b)
+	return ret
+}
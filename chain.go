@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errChainUnsupported = errors.New("not supported on this chain")
+	errInvalidRequest   = errors.New("invalid request")
+	// errChainConfig is a configuration that contradicts the chain, it stops the server
+	errChainConfig = errors.New("chain configuration does not match the chain")
+)
+
+// PayoutChain is a blockchain with a payout contract. The chains are created by name from CHAINS, see chainFactories.
+type PayoutChain interface {
+	Name() string
+	Config() ChainConfig
+	// SignClaim signs the total amount the user or account can withdraw
+	SignClaim(c Claim, subject string) (interface{}, error)
+	// PaidOut returns the amount the contract already paid out
+	PaidOut(c Claim) (*big.Int, error)
+	BatchPayout(addresses []string, amounts []*big.Int) (interface{}, error)
+	Time() (time.Time, error)
+}
+
+// ChainConfig is reported by /config for every enabled chain
+type ChainConfig struct {
+	Name     string `json:"name"`
+	Contract string `json:"contract"`
+	Network  string `json:"network"`
+	ChainId  int64  `json:"chainId,omitempty"`
+	SignMode string `json:"signMode,omitempty"`
+}
+
+// Claim identifies a withdrawal, ETH needs the UserId, the other chains the Address only
+type Claim struct {
+	UserId  string   `json:"userId,omitempty"`
+	Address string   `json:"address"`
+	Amount  *big.Int `json:"amount,omitempty"`
+}
+
+type ChainPayoutRequest struct {
+	Addresses []string   `json:"addresses"`
+	Amounts   []*big.Int `json:"amounts"`
+}
+
+// chainFactories deploy or bind the contract of a chain
+var chainFactories = map[string]func(o *Opts) (PayoutChain, error){
+	"eth": newEthChain,
+	"neo": newNeoChain,
+	"xtz": newXtzChain,
+}
+
+var (
+	chains     = map[string]PayoutChain{}
+	chainNames []string
+)
+
+// initChains creates the chains listed in CHAINS, a chain that cannot be initialized is disabled
+func initChains(o *Opts) {
+	for _, ec := range o.EthChains {
+		chainFactories[ec.Name] = evmChainFactory(ec)
+	}
+	for _, name := range strings.Split(o.Chains, ",") {
+		name = strings.TrimSpace(name)
+		f, ok := chainFactories[name]
+		if !ok {
+			log.Fatalf("unknown chain %v", name)
+		}
+		c, err := f(o)
+		if errors.Is(err, errChainConfig) {
+			log.Fatalf("Could not initialize chain %v: %v", name, err)
+		}
+		if err != nil {
+			log.Warnf("Could not initialize chain %v: %v", name, err)
+			continue
+		}
+		chains[name] = c
+		chainNames = append(chainNames, name)
+		log.Printf("chain %v enabled", name)
+	}
+}
+
+// checkBatch validates the addresses and amounts of a batch payout, the addresses are checked by the chain
+func checkBatch(addresses []string, amounts []*big.Int) error {
+	if len(addresses) == 0 || len(addresses) != len(amounts) {
+		return fmt.Errorf("%w: addresses and amounts must have the same, non-zero length: %v/%v", errInvalidRequest, len(addresses), len(amounts))
+	}
+	for i, a := range amounts {
+		if a == nil || a.Sign() <= 0 {
+			return fmt.Errorf("%w: invalid amount %v at %v", errInvalidRequest, a, i)
+		}
+	}
+	return nil
+}
+
+// chainErrStatus maps the errors of the chains to the HTTP status code
+func chainErrStatus(err error) int {
+	var neoErr *NeoError
+	var errDecreased *ErrAmountDecreased
+	var errNoNewFunds *ErrNoNewFunds
+	switch {
+	case errors.Is(err, errChainUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errContractNotVerified):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &neoErr):
+		return neoErrStatus(err)
+	case errors.As(err, &errDecreased) || errors.As(err, &errNoNewFunds):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func chainFromRequest(w http.ResponseWriter, r *http.Request) PayoutChain {
+	name := mux.Vars(r)["chain"]
+	c, ok := chains[name]
+	if !ok {
+		writeErr(w, http.StatusNotFound, "chain %v is not enabled", name)
+		return nil
+	}
+	return c
+}
+
+func chainSign(w http.ResponseWriter, r *http.Request, subject string) {
+	c := chainFromRequest(w, r)
+	if c == nil {
+		return
+	}
+	var data Claim
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode claim: %v", err)
+		return
+	}
+	if data.Amount == nil || data.Amount.Sign() <= 0 {
+		writeErr(w, http.StatusBadRequest, "invalid amount %v", data.Amount)
+		return
+	}
+	res, err := c.SignClaim(data, subject)
+	var errPolicy *ErrPolicy
+	if errors.As(err, &errPolicy) {
+		writeRuleErr(w, errPolicy)
+		return
+	}
+	if err != nil {
+		writeErr(w, chainErrStatus(err), "%v sign error %v", c.Name(), err)
+		return
+	}
+	if _, ok := res.(*PendingApproval); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+	}
+	writeJson(w, res)
+}
+
+func chainPaidOut(w http.ResponseWriter, r *http.Request, _ string) {
+	c := chainFromRequest(w, r)
+	if c == nil {
+		return
+	}
+	q := r.URL.Query()
+	claim := Claim{UserId: q.Get("userId"), Address: q.Get("address")}
+	paid, err := c.PaidOut(claim)
+	if err != nil {
+		writeErr(w, chainErrStatus(err), "%v paid out error %v", c.Name(), err)
+		return
+	}
+	claim.Amount = paid
+	writeJson(w, claim)
+}
+
+func chainPayout(w http.ResponseWriter, r *http.Request, _ string) {
+	c := chainFromRequest(w, r)
+	if c == nil {
+		return
+	}
+	var data ChainPayoutRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode payout request: %v", err)
+		return
+	}
+	err = checkBatch(data.Addresses, data.Amounts)
+	if err == nil {
+		var res interface{}
+		res, err = c.BatchPayout(data.Addresses, data.Amounts)
+		if err == nil {
+			writeJson(w, res)
+			return
+		}
+	}
+	writeErr(w, chainErrStatus(err), "%v payout error %v", c.Name(), err)
+}
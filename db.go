@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/binary"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var bucketLedger = []byte("ledger")
+var bucketLedgerMax = []byte("ledger_max")
+
+func openDB(path string) (*bolt.DB, error) {
+	d, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = d.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketLedger, bucketLedgerMax, bucketApprovals, bucketNeoJobs,
+			bucketNeoEvents, bucketNeoEventsAccount, bucketNeoIndexer, bucketNeoCosign, bucketEthAdmin,
+			bucketEthWithdrawals, bucketEthWithdrawalsUser, bucketEthIndexerBlocks} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func btoi(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
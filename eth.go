@@ -1,22 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/uuid"
 	"log"
 	"math/big"
+	"strings"
+	"sync"
 	"time"
 )
 
+var errContractNotVerified = errors.New("contract not verified, signing is disabled")
+
+// errEthDeploy is not retried, the contract may have been deployed already
+var errEthDeploy = errors.New("could not deploy contract")
+
 // PayoutEthMetaData contains all meta data concerning the PayoutEth contract.
 var PayoutEthMetaData = &bind.MetaData{
 	ABI: "[\n\t{\n\t\t\"inputs\": [],\n\t\t\"stateMutability\": \"nonpayable\",\n\t\t\"type\": \"constructor\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"address payable\",\n\t\t\t\t\"name\": \"newOwner\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"changeOwner\",\n\t\t\"outputs\": [],\n\t\t\"stateMutability\": \"nonpayable\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"userId\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"totalPayedOut\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"getClaimableAmount\",\n\t\t\"outputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"stateMutability\": \"view\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"userId\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"getPayedOut\",\n\t\t\"outputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"stateMutability\": \"view\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [],\n\t\t\"name\": \"owner\",\n\t\t\"outputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"address\",\n\t\t\t\t\"name\": \"\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t}\n\t\t],\n\t\t\"stateMutability\": \"view\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"payedOut\",\n\t\t\"outputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"stateMutability\": \"view\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"address payable\",\n\t\t\t\t\"name\": \"receiver\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"amount\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"sndRecoverEth\",\n\t\t\"outputs\": [],\n\t\t\"stateMutability\": \"nonpayable\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"address\",\n\t\t\t\t\"name\": \"receiver\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"address\",\n\t\t\t\t\"name\": \"contractAddress\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"amount\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"sndRecoverToken\",\n\t\t\"outputs\": [],\n\t\t\"stateMutability\": \"nonpayable\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"inputs\": [\n\t\t\t{\n\t\t\t\t\"internalType\": \"address payable\",\n\t\t\t\t\"name\": \"dev\",\n\t\t\t\t\"type\": \"address\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"userId\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint256\",\n\t\t\t\t\"name\": \"totalPayedOut\",\n\t\t\t\t\"type\": \"uint256\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"uint8\",\n\t\t\t\t\"name\": \"v\",\n\t\t\t\t\"type\": \"uint8\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"r\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t},\n\t\t\t{\n\t\t\t\t\"internalType\": \"bytes32\",\n\t\t\t\t\"name\": \"s\",\n\t\t\t\t\"type\": \"bytes32\"\n\t\t\t}\n\t\t],\n\t\t\"name\": \"withdraw\",\n\t\t\"outputs\": [],\n\t\t\"stateMutability\": \"nonpayable\",\n\t\t\"type\": \"function\"\n\t},\n\t{\n\t\t\"stateMutability\": \"payable\",\n\t\t\"type\": \"receive\"\n\t}\n]",
@@ -33,75 +42,93 @@ var PayoutEthMetaData = &bind.MetaData{
 	Bin: "0x608060405234801561001057600080fd5b50600180546001600160a01b03191633179055610772806100326000396000f3fe60806040526004361061007f5760003560e01c80638da5cb5b1161004e5780638da5cb5b1461012d5780638e0fb98d14610165578063a6f9dae114610192578063db6e81ef146101b257600080fd5b80631b31a37f1461008b5780634c293714146100ad57806371676bd6146100ed57806374214d411461010d57600080fd5b3661008657005b600080fd5b34801561009757600080fd5b506100ab6100a636600461060b565b6101d2565b005b3480156100b957600080fd5b506100da6100c836600461069a565b60006020819052908152604090205481565b6040519081526020015b60405180910390f35b3480156100f957600080fd5b506100ab6101083660046105a9565b610240565b34801561011957600080fd5b506100ab610128366004610637565b61041b565b34801561013957600080fd5b5060015461014d906001600160a01b031681565b6040516001600160a01b0390911681526020016100e4565b34801561017157600080fd5b506100da61018036600461069a565b60009081526020819052604090205490565b34801561019e57600080fd5b506100ab6101ad36600461058c565b6104d1565b3480156101be57600080fd5b506100da6101cd3660046106b3565b61051d565b6001546001600160a01b031633146102055760405162461bcd60e51b81526004016101fc906106d5565b60405180910390fd5b6040516001600160a01b0383169082156108fc029083906000818181858888f1935050505015801561023b573d6000803e3d6000fd5b505050565b600085815260208190526040902054841161029d5760405162461bcd60e51b815260206004820152601c60248201527f4e6f206e65772066756e647320746f2062652077697468647261776e0000000060448201526064016101fc565b6001805460408051602081018990529081018790526001600160a01b03909116919060600160408051601f198184030181529082905280516020918201207f19457468657265756d205369676e6564204d6573736167653a0a36360000000091830191909152603c820152605c0160408051601f198184030181528282528051602091820120600084529083018083525260ff871690820152606081018590526080810184905260a0016020604051602081039080840390855afa158015610369573d6000803e3d6000fd5b505050602060405103516001600160a01b0316146103be5760405162461bcd60e51b81526020600482015260126024820152710a6d2cedcc2e8eae4ca40dcde40dac2e8c6d60731b60448201526064016101fc565b60008581526020819052604090208054908590556001600160a01b0387166108fc6103e983886106ff565b6040518115909202916000818181858888f19350505050158015610411573d6000803e3d6000fd5b5050505050505050565b6001546001600160a01b031633146104455760405162461bcd60e51b81526004016101fc906106d5565b60405163a9059cbb60e01b81526001600160a01b0384811660048301526024820183905283919082169063a9059cbb90604401602060405180830381600087803b15801561049257600080fd5b505af11580156104a6573d6000803e3d6000fd5b505050506040513d601f19601f820116820180604052508101906104ca9190610678565b5050505050565b6001546001600160a01b031633146104fb5760405162461bcd60e51b81526004016101fc906106d5565b600180546001600160a01b0319166001600160a01b0392909216919091179055565b60008281526020819052604081205482101561056c5760405162461bcd60e51b815260206004820152600e60248201526d4e656761746976652066756e647360901b60448201526064016101fc565b60008381526020819052604090205461058590836106ff565b9392505050565b60006020828403121561059e57600080fd5b813561058581610724565b60008060008060008060c087890312156105c257600080fd5b86356105cd81610724565b95506020870135945060408701359350606087013560ff811681146105f157600080fd5b9598949750929560808101359460a0909101359350915050565b6000806040838503121561061e57600080fd5b823561062981610724565b946020939093013593505050565b60008060006060848603121561064c57600080fd5b833561065781610724565b9250602084013561066781610724565b929592945050506040919091013590565b60006020828403121561068a57600080fd5b8151801515811461058557600080fd5b6000602082840312156106ac57600080fd5b5035919050565b600080604083850312156106c657600080fd5b50508035926020909101359150565b60208082526010908201526f27379030baba3437b934bd30ba34b7b760811b604082015260600190565b60008282101561071f57634e487b7160e01b600052601160045260246000fd5b500390565b6001600160a01b038116811461073957600080fd5b5056fea2646970667358221220556a5e5683dc8cbae0a092e52a6ce177fe2c22c6822b1759c143ec87b37a37f164736f6c63430008070033",
 }
 
+// payoutEthBins are the contracts of the sign modes. They share the ABI of PayoutEth, PayoutEthEip712 adds
+// domainSeparator(). Bound and eip712 are compiled from the .sol files with solc 0.8.21, optimizer with 200 runs,
+// evmVersion london and metadata.bytecodeHash none. Legacy is the binary of the PayoutEth binding, unlike
+// PayoutEth.sol it checks the signature of the hash with the Ethereum signed message prefix.
+var payoutEthBins = map[string]string{
+	SignModeLegacy: PayoutEthMetaData.Bin,
+	SignModeBound:  "0x608060405234801561001057600080fd5b50600180546001600160a01b031916331790556106d0806100326000396000f3fe60806040526004361061007f5760003560e01c80638da5cb5b1161004e5780638da5cb5b1461012d5780638e0fb98d14610165578063a6f9dae114610192578063db6e81ef146101b257600080fd5b80631b31a37f1461008b5780634c293714146100ad57806371676bd6146100ed57806374214d411461010d57600080fd5b3661008657005b600080fd5b34801561009757600080fd5b506100ab6100a6366004610528565b6101d2565b005b3480156100b957600080fd5b506100da6100c8366004610554565b60006020819052908152604090205481565b6040519081526020015b60405180910390f35b3480156100f957600080fd5b506100ab61010836600461056d565b610240565b34801561011957600080fd5b506100ab6101283660046105cf565b6103ff565b34801561013957600080fd5b5060015461014d906001600160a01b031681565b6040516001600160a01b0390911681526020016100e4565b34801561017157600080fd5b506100da610180366004610554565b60009081526020819052604090205490565b34801561019e57600080fd5b506100ab6101ad366004610610565b6104a2565b3480156101be57600080fd5b506100da6101cd366004610634565b6104ee565b6001546001600160a01b031633146102055760405162461bcd60e51b81526004016101fc90610656565b60405180910390fd5b6040516001600160a01b0383169082156108fc029083906000818181858888f1935050505015801561023b573d6000803e3d6000fd5b505050565b600085815260208190526040902054841161029d5760405162461bcd60e51b815260206004820152601c60248201527f4e6f206e65772066756e647320746f2062652077697468647261776e0000000060448201526064016101fc565b600180546040805160208101899052602360f81b9181018290526041810188905260618101919091526bffffffffffffffffffffffff1960608a901b1660628201526001600160a01b03909116919060760160408051601f198184030181528282528051602091820120600084529083018083525260ff871690820152606081018590526080810184905260a0016020604051602081039080840390855afa15801561034d573d6000803e3d6000fd5b505050602060405103516001600160a01b0316146103a25760405162461bcd60e51b81526020600482015260126024820152710a6d2cedcc2e8eae4ca40dcde40dac2e8c6d60731b60448201526064016101fc565b60008581526020819052604090208054908590556001600160a01b0387166108fc6103cd8388610680565b6040518115909202916000818181858888f193505050501580156103f5573d6000803e3d6000fd5b5050505050505050565b6001546001600160a01b031633146104295760405162461bcd60e51b81526004016101fc90610656565b60405163a9059cbb60e01b81526001600160a01b0384811660048301526024820183905283169063a9059cbb906044016020604051808303816000875af1158015610478573d6000803e3d6000fd5b505050506040513d601f19601f8201168201806040525081019061049c91906106a1565b50505050565b6001546001600160a01b031633146104cc5760405162461bcd60e51b81526004016101fc90610656565b600180546001600160a01b0319166001600160a01b0392909216919091179055565b6000828152602081905260408120546105079083610680565b90505b92915050565b6001600160a01b038116811461052557600080fd5b50565b6000806040838503121561053b57600080fd5b823561054681610510565b946020939093013593505050565b60006020828403121561056657600080fd5b5035919050565b60008060008060008060c0878903121561058657600080fd5b863561059181610510565b95506020870135945060408701359350606087013560ff811681146105b557600080fd5b9598949750929560808101359460a0909101359350915050565b6000806000606084860312156105e457600080fd5b83356105ef81610510565b925060208401356105ff81610510565b929592945050506040919091013590565b60006020828403121561062257600080fd5b813561062d81610510565b9392505050565b6000806040838503121561064757600080fd5b50508035926020909101359150565b60208082526010908201526f27379030baba3437b934bd30ba34b7b760811b604082015260600190565b8181038181111561050a57634e487b7160e01b600052601160045260246000fd5b6000602082840312156106b357600080fd5b8151801515811461062d57600080fdfea164736f6c6343000815000a",
+	SignModeEip712: "0x608060405234801561001057600080fd5b50600180546001600160a01b031916331790556107eb806100326000396000f3fe60806040526004361061008a5760003560e01c80638da5cb5b116100595780638da5cb5b146101385780638e0fb98d14610170578063a6f9dae11461019d578063db6e81ef146101bd578063f698da25146101dd57600080fd5b80631b31a37f146100965780634c293714146100b857806371676bd6146100f857806374214d411461011857600080fd5b3661009157005b600080fd5b3480156100a257600080fd5b506100b66100b1366004610643565b6101f2565b005b3480156100c457600080fd5b506100e56100d336600461066f565b60006020819052908152604090205481565b6040519081526020015b60405180910390f35b34801561010457600080fd5b506100b6610113366004610688565b610260565b34801561012457600080fd5b506100b66101333660046106ea565b610475565b34801561014457600080fd5b50600154610158906001600160a01b031681565b6040516001600160a01b0390911681526020016100ef565b34801561017c57600080fd5b506100e561018b36600461066f565b60009081526020819052604090205490565b3480156101a957600080fd5b506100b66101b836600461072b565b610518565b3480156101c957600080fd5b506100e56101d836600461074f565b610564565b3480156101e957600080fd5b506100e5610586565b6001546001600160a01b031633146102255760405162461bcd60e51b815260040161021c90610771565b60405180910390fd5b6040516001600160a01b0383169082156108fc029083906000818181858888f1935050505015801561025b573d6000803e3d6000fd5b505050565b60008581526020819052604090205484116102bd5760405162461bcd60e51b815260206004820152601c60248201527f4e6f206e65772066756e647320746f2062652077697468647261776e00000000604482015260640161021c565b604080517f6a1f109cddba597f2750e45b90f77c24e8ae33c0ac34a25fa8639128f9d12e3160208201526001600160a01b03881691810191909152606081018690526080810185905260009060a001604051602081830303815290604052805190602001209050600061032e610586565b60405161190160f01b602082015260228101919091526042810183905260620160408051808303601f190181528282528051602091820120600180546000865292850180855282905260ff8a1693850193909352606084018890526080840187905293506001600160a01b03169160a0016020604051602081039080840390855afa1580156103c1573d6000803e3d6000fd5b505050602060405103516001600160a01b0316146104165760405162461bcd60e51b81526020600482015260126024820152710a6d2cedcc2e8eae4ca40dcde40dac2e8c6d60731b604482015260640161021c565b60008781526020819052604090208054908790556001600160a01b0389166108fc610441838a61079b565b6040518115909202916000818181858888f19350505050158015610469573d6000803e3d6000fd5b50505050505050505050565b6001546001600160a01b0316331461049f5760405162461bcd60e51b815260040161021c90610771565b60405163a9059cbb60e01b81526001600160a01b0384811660048301526024820183905283169063a9059cbb906044016020604051808303816000875af11580156104ee573d6000803e3d6000fd5b505050506040513d601f19601f8201168201806040525081019061051291906107bc565b50505050565b6001546001600160a01b031633146105425760405162461bcd60e51b815260040161021c90610771565b600180546001600160a01b0319166001600160a01b0392909216919091179055565b60008281526020819052604081205461057d908361079b565b90505b92915050565b604080517f8b73c3c69bb8fe3d512ecc4cf759cc79239f7b179b0ffacaa9a75d522b39400f60208201527fdd8fd724ffbffed7333d92a09df76e0c2d7d148f299bca30145b76ba1e34082a918101919091527fc89efdaa54c0f20c7adf612882df0950f5a951637e0307cdcb4c672f298b8bc660608201524660808201523060a082015260009060c00160405160208183030381529060405280519060200120905090565b6001600160a01b038116811461064057600080fd5b50565b6000806040838503121561065657600080fd5b82356106618161062b565b946020939093013593505050565b60006020828403121561068157600080fd5b5035919050565b60008060008060008060c087890312156106a157600080fd5b86356106ac8161062b565b95506020870135945060408701359350606087013560ff811681146106d057600080fd5b9598949750929560808101359460a0909101359350915050565b6000806000606084860312156106ff57600080fd5b833561070a8161062b565b9250602084013561071a8161062b565b929592945050506040919091013590565b60006020828403121561073d57600080fd5b81356107488161062b565b9392505050565b6000806040838503121561076257600080fd5b50508035926020909101359150565b60208082526010908201526f27379030baba3437b934bd30ba34b7b760811b604082015260600190565b8181038181111561058057634e487b7160e01b600052601160045260246000fd5b6000602082840312156107ce57600080fd5b8151801515811461074857600080fdfea164736f6c6343000815000a",
+}
+
 type ClientETH struct {
+	name        string
 	c           *ethclient.Client
 	rpc         *rpc.Client
-	privateKey  *ecdsa.PrivateKey
-	publicKey   *ecdsa.PublicKey
+	signer      Signer
+	signMode    string
 	fromAddress common.Address
 	chainId     *big.Int
+	address     common.Address
 	contract    *bind.BoundContract
+	prefixed    bool
+	mu          sync.RWMutex
+	verifyErr   error
 }
 
-func getEthClient(ethUrl string, hexPrivateKey string, deploy bool, ethContract string) (*ClientETH, error) {
-	rpc, err := rpc.DialContext(context.Background(), ethUrl)
+func getEthClient(ec EthChain, signer Signer) (*ClientETH, error) {
+	rpc, err := rpc.DialContext(context.Background(), ec.Blockchain.Url)
 	if err != nil {
 		return nil, err
 	}
 	client := ethclient.NewClient(rpc)
 
-	if err != nil {
-		return nil, err
-	}
-	privateKey, err := crypto.HexToECDSA(hexPrivateKey)
-	if err != nil {
-		return nil, err
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, errors.New("error casting public key to ECDSA")
-	}
-
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
+	fromAddress := signer.Address()
 
 	c := &ClientETH{
-		c:          client,
-		rpc:        rpc,
-		privateKey: privateKey,
-		publicKey:  publicKeyECDSA,
+		name:        ec.Name,
+		c:           client,
+		rpc:         rpc,
+		signer:      signer,
+		signMode:    ec.SignMode,
+		fromAddress: fromAddress,
 	}
 
-	chainId, err := c.c.NetworkID(context.Background())
+	//eth_chainId, the network id differs from the chain id on some networks
+	chainId, err := c.c.ChainID(context.Background())
 	if err != nil {
 		return nil, err
 	}
 	c.chainId = chainId
 
 	fmt.Println("---------------------------------")
-	fmt.Printf("My chain Id is %v\n", chainId)
+	fmt.Printf("My %v chain Id is %v\n", ec.Name, chainId)
 	fmt.Printf("My address is %v\n", fromAddress)
 	fmt.Println("---------------------------------")
 
 	parsed, err := PayoutEthMetaData.GetAbi()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	if deploy {
-		log.Printf("Start deploying ETH Contract...")
-		var addr common.Address
-		c.contract, addr = deployEthContract(c, *parsed)
-		opts.Ethereum.Contract = addr.Hex()
+	if ec.Blockchain.Deploy {
+		log.Printf("Start deploying %v Contract...", ec.Name)
+		c.contract, c.address, err = deployEthContract(c, *parsed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errEthDeploy, err)
+		}
 	} else {
-		c.contract = bind.NewBoundContract(common.HexToAddress(ethContract), *parsed, c.c, c.c, c.c)
+		c.address = common.HexToAddress(ec.Blockchain.Contract)
+		c.contract = bind.NewBoundContract(c.address, *parsed, c.c, c.c, c.c)
+	}
+
+	code, err := c.c.CodeAt(context.Background(), c.address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read code: %w", err)
+	}
+	codeHash := crypto.Keccak256Hash(code)
+	err = c.checkSignMode(codeHash)
+	if err != nil {
+		return nil, err
 	}
+	c.prefixed = isPrefixedEthCode(codeHash)
+	c.verify()
 
 	// get time
 	header, err := client.HeaderByNumber(context.Background(), nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	//forward to the time we should be
@@ -115,7 +142,7 @@ func getEthClient(ethUrl string, hexPrivateKey string, deploy bool, ethContract
 	// show time
 	header, err = client.HeaderByNumber(context.Background(), nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	fmt.Println("---------------------------------")
@@ -126,33 +153,163 @@ func getEthClient(ethUrl string, hexPrivateKey string, deploy bool, ethContract
 	return c, nil
 }
 
-func deployEthContract(ethClient *ClientETH, abi abi.ABI) (*bind.BoundContract, common.Address) {
-	opts, err := bind.NewKeyedTransactorWithChainID(ethClient.privateKey, ethClient.chainId)
+func deployEthContract(ethClient *ClientETH, abi abi.ABI) (*bind.BoundContract, common.Address, error) {
+	opts := ethClient.transactOpts()
 
 	//param []
 
-	address, tx, contract, err := bind.DeployContract(opts, abi, common.FromHex(PayoutEthMetaData.Bin), ethClient.c)
-
+	address, tx, contract, err := bind.DeployContract(opts, abi, common.FromHex(payoutEthBins[ethClient.signMode]), ethClient.c)
 	if err != nil {
-		log.Fatal(err)
+		return nil, common.Address{}, err
 	}
 
 	//mine immediately
 	var result hexutil.Big
 	err = ethClient.rpc.CallContext(context.Background(), &result, "evm_mine")
 	if err != nil {
-		log.Fatal(err)
+		return nil, common.Address{}, err
 	}
 
 	_, err = bind.WaitDeployed(context.Background(), ethClient.c, tx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, common.Address{}, err
 	}
 
 	fmt.Println("---------------------------------")
-	log.Printf("ETH Contract deployed at %v", address)
+	log.Printf("%v Contract deployed at %v", ethClient.name, address)
 	fmt.Println("---------------------------------")
-	return contract, address
+	return contract, address, nil
+}
+
+// verify checks the code and the owner of the bound contract. Until it succeeds, no signatures are issued, as they
+// could never be redeemed.
+func (c *ClientETH) verify() {
+	err := c.verifyContract()
+	if err != nil {
+		log.Printf("%v contract %v is not verified, signing is disabled: %v", c.name, c.address, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verifyErr = err
+}
+
+// verifyContract checks that the code at the contract address is the runtime code of the embedded contract of the
+// sign mode or has a hash of ETH_CODE_HASHES, and that owner() is the signer
+func (c *ClientETH) verifyContract() error {
+	code, err := c.c.CodeAt(context.Background(), c.address, nil)
+	if err != nil {
+		return fmt.Errorf("could not read code: %w", err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no contract at %v", c.address)
+	}
+	codeHash := crypto.Keccak256Hash(code)
+	if !isKnownEthCode(c.signMode, codeHash) {
+		return fmt.Errorf("code with hash %v is neither the %v contract nor in ETH_CODE_HASHES", codeHash, c.signMode)
+	}
+	owner, err := c.owner()
+	if err != nil {
+		return fmt.Errorf("could not read owner: %w", err)
+	}
+	if owner != c.fromAddress {
+		return fmt.Errorf("owner is %v, not the signer %v", owner, c.fromAddress)
+	}
+	return nil
+}
+
+// checkVerified returns errContractNotVerified if the last verification failed
+func (c *ClientETH) checkVerified() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.verifyErr != nil {
+		return fmt.Errorf("%w: %v", errContractNotVerified, c.verifyErr)
+	}
+	return nil
+}
+
+// checkSignMode refuses a contract whose code is the embedded contract of another sign mode, its signatures would
+// never be accepted
+func (c *ClientETH) checkSignMode(codeHash common.Hash) error {
+	mode := ethCodeSignMode(codeHash)
+	if mode != "" && mode != c.signMode {
+		return fmt.Errorf("%w: %v contract %v is the %v contract, the sign mode is %v", errChainConfig, c.name, c.address, mode, c.signMode)
+	}
+	return nil
+}
+
+// isKnownEthCode returns true if the code hash is the one of the embedded contract of the sign mode or is allowed
+func isKnownEthCode(signMode string, codeHash common.Hash) bool {
+	if ethCodeSignMode(codeHash) == signMode {
+		return true
+	}
+	for _, h := range strings.Split(opts.EthCodeHashes, ",") {
+		if h = strings.TrimSpace(h); h != "" && common.HexToHash(h) == codeHash {
+			return true
+		}
+	}
+	return false
+}
+
+// ethCodeSignMode returns the sign mode of the embedded contract with the runtime code hash, or "" if there is none
+func ethCodeSignMode(codeHash common.Hash) string {
+	for mode, bin := range payoutEthBins {
+		if crypto.Keccak256Hash(ethRuntime(bin)) == codeHash {
+			return mode
+		}
+	}
+	return ""
+}
+
+// isPrefixedEthCode returns true for the embedded legacy contract, which checks the signature of the hash with the
+// "\x19Ethereum Signed Message:\n32" prefix. Legacy contracts of ETH_CODE_HASHES, e.g., compiled from PayoutEth.sol,
+// check the hash as is.
+func isPrefixedEthCode(codeHash common.Hash) bool {
+	return ethCodeSignMode(codeHash) == SignModeLegacy
+}
+
+// ethRuntime returns the runtime part of a contract binary. The constructor ends with RETURN INVALID (0xf3 0xfe) and
+// is followed by the runtime code, there are no constructor arguments.
+func ethRuntime(bin string) []byte {
+	b := common.FromHex(bin)
+	i := bytes.Index(b, []byte{0xf3, 0xfe})
+	if i < 0 {
+		return nil
+	}
+	return b[i+2:]
+}
+
+// owner reads owner() of the bound contract, this is the address the signatures have to be created with
+func (c *ClientETH) owner() (common.Address, error) {
+	var out []interface{}
+	err := c.contract.Call(&bind.CallOpts{Context: context.Background()}, &out, "owner")
+	if err != nil {
+		return common.Address{}, err
+	}
+	return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+}
+
+// payedOut reads getPayedOut(userId) of the bound contract, the amount that was already withdrawn by this user
+func (c *ClientETH) payedOut(userId [32]byte) (*big.Int, error) {
+	var out []interface{}
+	err := c.contract.Call(&bind.CallOpts{Context: context.Background()}, &out, "getPayedOut", userId)
+	if err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// transactOpts creates the options to send transactions signed by the configured signer
+func (c *ClientETH) transactOpts() *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: c.fromAddress,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != c.fromAddress {
+				return nil, bind.ErrNotAuthorized
+			}
+			return c.signer.SignTx(tx, c.chainId)
+		},
+		Context: context.Background(),
+	}
 }
 
 func warpChain(seconds int, rpc *rpc.Client) error {
@@ -171,3 +328,141 @@ func warpChain(seconds int, rpc *rpc.Client) error {
 
 	return nil
 }
+
+// addEthClient registers an EVM chain by its chain id. Legacy and bound signatures do not contain the chain, so
+// two chains with the same signer must not use these sign modes, a signature could be replayed on the other chain.
+func addEthClient(c *ClientETH) error {
+	if other, ok := ethClients[c.chainId.Int64()]; ok {
+		return fmt.Errorf("%w: %v and %v have the same chain id %v", errChainConfig, other.name, c.name, c.chainId)
+	}
+	for _, other := range ethClients {
+		if other.fromAddress == c.fromAddress && (other.signMode != SignModeEip712 || c.signMode != SignModeEip712) {
+			return fmt.Errorf("%w: %v and %v share the signer %v, signatures could be replayed, use sign mode %v or another signer",
+				errChainConfig, other.name, c.name, c.fromAddress, SignModeEip712)
+		}
+	}
+	ethClients[c.chainId.Int64()] = c
+	return nil
+}
+
+// ethChainById returns the EVM chain with the chain id, 0 is the ETH chain
+func ethChainById(chainId int64) (*ClientETH, error) {
+	if chainId == 0 {
+		if ethClient == nil {
+			return nil, fmt.Errorf("%w: ETH is not enabled", errInvalidRequest)
+		}
+		return ethClient, nil
+	}
+	c, ok := ethClients[chainId]
+	if !ok {
+		return nil, fmt.Errorf("%w: chain id %v is not enabled", errInvalidRequest, chainId)
+	}
+	return c, nil
+}
+
+// ethChain is the ETH chain or one of ETH_CHAINS
+type ethChain struct {
+	c *ClientETH
+}
+
+func newEthChain(o *Opts) (PayoutChain, error) {
+	c, err := startEthChain(EthChain{Name: "eth", Blockchain: o.Ethereum, SignMode: o.EthSignMode, IndexerStart: o.EthIndexerStart}, ethSigner)
+	if err != nil {
+		return nil, err
+	}
+	ethClient = c
+	return &ethChain{c: c}, nil
+}
+
+// evmChainFactory creates the factory of an additional EVM chain
+func evmChainFactory(ec EthChain) func(o *Opts) (PayoutChain, error) {
+	return func(_ *Opts) (PayoutChain, error) {
+		signer, err := newEthChainSigner(ec)
+		if err != nil {
+			return nil, err
+		}
+		c, err := startEthChain(ec, signer)
+		if err != nil {
+			return nil, err
+		}
+		return &ethChain{c: c}, nil
+	}
+}
+
+// startEthChain connects to the chain, registers it and starts the indexer if ETH_INDEXER is set
+func startEthChain(ec EthChain, signer Signer) (*ClientETH, error) {
+	c, err := ethInit(ec, signer)
+	if err != nil {
+		return nil, err
+	}
+	err = addEthClient(c)
+	if err != nil {
+		return nil, err
+	}
+	if opts.EthIndexer {
+		go ethIndexer(c, uint64(ec.IndexerStart))
+	}
+	return c, nil
+}
+
+func (e *ethChain) Name() string {
+	return e.c.name
+}
+
+func (e *ethChain) Config() ChainConfig {
+	return ChainConfig{
+		Name:     e.Name(),
+		Contract: e.c.address.Hex(),
+		Network:  e.c.chainId.String(),
+		ChainId:  e.c.chainId.Int64(),
+		SignMode: e.c.signMode,
+	}
+}
+
+// SignClaim signs with the ledger and policy checks of /admin/sign, it may return a PendingApproval
+func (e *ethChain) SignClaim(c Claim, subject string) (interface{}, error) {
+	userId, err := uuid.Parse(c.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: userId %v: %v", errInvalidRequest, c.UserId, err)
+	}
+	var dev common.Address
+	if c.Address != "" {
+		if !common.IsHexAddress(c.Address) {
+			return nil, fmt.Errorf("%w: address %v", errInvalidRequest, c.Address)
+		}
+		dev = common.HexToAddress(c.Address)
+	}
+	sig, err := signAndRecord(e.c, userId, c.Amount, dev, subject, false)
+	if isApprovalRequired(err) {
+		a, err := createApproval(e.c, userId, c.Amount, dev, subject, err.Error())
+		if err != nil {
+			return nil, fmt.Errorf("could not create approval: %w", err)
+		}
+		return &PendingApproval{ApprovalId: a.Id, Status: a.Status, Rule: RuleApprovalThreshold}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (e *ethChain) PaidOut(c Claim) (*big.Int, error) {
+	userId, err := uuid.Parse(c.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: userId %v: %v", errInvalidRequest, c.UserId, err)
+	}
+	return e.c.payedOut(userIdBytes32(userId))
+}
+
+// BatchPayout is not supported, PayoutEth is withdrawn by the developers
+func (e *ethChain) BatchPayout(_ []string, _ []*big.Int) (interface{}, error) {
+	return nil, errChainUnsupported
+}
+
+func (e *ethChain) Time() (time.Time, error) {
+	header, err := e.c.c.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0).UTC(), nil
+}
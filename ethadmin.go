@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	EthAdminPending  = "pending"
+	EthAdminSuccess  = "success"
+	EthAdminReverted = "reverted"
+	EthAdminFailed   = "failed"
+)
+
+// ethAdminTimeout is how long the receipt of an owner transaction is waited for
+const ethAdminTimeout = 5 * time.Minute
+
+var bucketEthAdmin = []byte("eth_admin")
+
+var errEthNotOwner = errors.New("signer is not the contract owner")
+
+// serializes the owner transactions, so they do not get the same nonce
+var ethAdminMu sync.Mutex
+
+// EthAdminTx is the audit log entry of an owner operation on PayoutEth. It is written before the transaction is sent
+// and updated with the receipt.
+type EthAdminTx struct {
+	Id          uint64            `json:"id"`
+	Admin       string            `json:"admin"`
+	Method      string            `json:"method"`
+	Chain       string            `json:"chain"`
+	ChainId     int64             `json:"chainId"`
+	Contract    common.Address    `json:"contract"`
+	Params      map[string]string `json:"params"`
+	Status      string            `json:"status"`
+	TxHash      *common.Hash      `json:"txHash,omitempty"`
+	BlockNumber uint64            `json:"blockNumber,omitempty"`
+	GasUsed     uint64            `json:"gasUsed,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+type EthAdminPage struct {
+	Entries []EthAdminTx `json:"entries"`
+	Next    uint64       `json:"next,omitempty"`
+}
+
+// EthChangeOwnerRequest is the request for changeOwner, ChainId selects the EVM chain, the ETH chain if 0
+type EthChangeOwnerRequest struct {
+	ChainId  int64          `json:"chainId,omitempty"`
+	NewOwner common.Address `json:"newOwner"`
+}
+
+// EthRecoverRequest is the request for sndRecoverEth, and for sndRecoverToken with the ERC-20 Token
+type EthRecoverRequest struct {
+	ChainId  int64          `json:"chainId,omitempty"`
+	Receiver common.Address `json:"receiver"`
+	Token    common.Address `json:"token,omitempty"`
+	Amount   *big.Int       `json:"amount"`
+}
+
+// sendEthAdminTx sends the owner transaction and waits for its receipt, every step is written to the audit log. The
+// returned entry is set as soon as it is in the audit log, also if an error is returned.
+func sendEthAdminTx(c *ClientETH, admin string, method string, params map[string]string, args ...interface{}) (*EthAdminTx, error) {
+	ethAdminMu.Lock()
+	defer ethAdminMu.Unlock()
+
+	now := timeNow()
+	a := &EthAdminTx{
+		Admin:     admin,
+		Method:    method,
+		Chain:     c.name,
+		ChainId:   c.chainId.Int64(),
+		Contract:  c.address,
+		Params:    params,
+		Status:    EthAdminPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := saveEthAdminTx(a); err != nil {
+		return nil, fmt.Errorf("could not write audit log: %w", err)
+	}
+	log.Printf("%v: %v %v on %v by %v", a.Id, method, params, c.name, admin)
+
+	owner, err := c.owner()
+	if err != nil {
+		return a, failEthAdminTx(a, fmt.Errorf("could not read contract owner: %w", err))
+	}
+	if owner != c.fromAddress {
+		return a, failEthAdminTx(a, fmt.Errorf("%w: %v, owner is %v", errEthNotOwner, c.fromAddress, owner))
+	}
+
+	tx, err := c.contract.Transact(c.transactOpts(), method, args...)
+	if err != nil {
+		return a, failEthAdminTx(a, err)
+	}
+	h := tx.Hash()
+	a.TxHash = &h
+	if err = saveEthAdminTx(a); err != nil {
+		return a, fmt.Errorf("could not write audit log: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ethAdminTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, c.c, tx)
+	if err != nil {
+		//the transaction is sent and may still be mined, so it stays pending
+		a.Error = fmt.Sprintf("no receipt: %v", err)
+		if errSave := saveEthAdminTx(a); errSave != nil {
+			log.Printf("could not write audit log: %v", errSave)
+		}
+		return a, fmt.Errorf("transaction %v: %v", h, a.Error)
+	}
+	a.BlockNumber = receipt.BlockNumber.Uint64()
+	a.GasUsed = receipt.GasUsed
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		a.Status = EthAdminSuccess
+	} else {
+		a.Status = EthAdminReverted
+	}
+	log.Printf("%v: %v transaction %v is %v", a.Id, method, h, a.Status)
+	if method == "changeOwner" && a.Status == EthAdminSuccess {
+		log.Warnf("owner of %v changed to %v, the signatures of %v are no longer valid", c.name, params["newOwner"], c.fromAddress)
+		c.verify()
+	}
+	return a, saveEthAdminTx(a)
+}
+
+func failEthAdminTx(a *EthAdminTx, err error) error {
+	a.Status = EthAdminFailed
+	a.Error = err.Error()
+	if errSave := saveEthAdminTx(a); errSave != nil {
+		return fmt.Errorf("%w, could not write audit log: %v", err, errSave)
+	}
+	return err
+}
+
+// saveEthAdminTx writes the entry to the audit log, a new entry gets the next id
+func saveEthAdminTx(a *EthAdminTx) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketEthAdmin)
+		if a.Id == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			a.Id = id
+		}
+		a.UpdatedAt = timeNow()
+		j, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(a.Id), j)
+	})
+}
+
+func writeEthAdminTx(w http.ResponseWriter, a *EthAdminTx, err error) {
+	if a == nil {
+		writeErr(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("%v: %v failed: %v", a.Id, a.Method, err)
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, errEthNotOwner) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}
+	writeJson(w, a)
+}
+
+func ethChangeOwner(w http.ResponseWriter, r *http.Request, email string) {
+	var data EthChangeOwnerRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode request: %v", err)
+		return
+	}
+	if data.NewOwner == (common.Address{}) {
+		writeErr(w, http.StatusBadRequest, "newOwner is required")
+		return
+	}
+	c, err := ethChainById(data.ChainId)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	a, err := sendEthAdminTx(c, email, "changeOwner", map[string]string{"newOwner": data.NewOwner.Hex()}, data.NewOwner)
+	writeEthAdminTx(w, a, err)
+}
+
+func ethRecoverEth(w http.ResponseWriter, r *http.Request, email string) {
+	data, c := ethRecoverRequest(w, r)
+	if c == nil {
+		return
+	}
+	params := map[string]string{"receiver": data.Receiver.Hex(), "amount": data.Amount.String()}
+	a, err := sendEthAdminTx(c, email, "sndRecoverEth", params, data.Receiver, data.Amount)
+	writeEthAdminTx(w, a, err)
+}
+
+func ethRecoverToken(w http.ResponseWriter, r *http.Request, email string) {
+	data, c := ethRecoverRequest(w, r)
+	if c == nil {
+		return
+	}
+	if data.Token == (common.Address{}) {
+		writeErr(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	params := map[string]string{"receiver": data.Receiver.Hex(), "token": data.Token.Hex(), "amount": data.Amount.String()}
+	a, err := sendEthAdminTx(c, email, "sndRecoverToken", params, data.Receiver, data.Token, data.Amount)
+	writeEthAdminTx(w, a, err)
+}
+
+func ethRecoverRequest(w http.ResponseWriter, r *http.Request) (*EthRecoverRequest, *ClientETH) {
+	var data EthRecoverRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode request: %v", err)
+		return nil, nil
+	}
+	if data.Receiver == (common.Address{}) {
+		writeErr(w, http.StatusBadRequest, "receiver is required")
+		return nil, nil
+	}
+	if data.Amount == nil || data.Amount.Sign() <= 0 {
+		writeErr(w, http.StatusBadRequest, "invalid amount %v", data.Amount)
+		return nil, nil
+	}
+	c, err := ethChainById(data.ChainId)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "%v", err)
+		return nil, nil
+	}
+	return &data, c
+}
+
+// ethAdminAudit returns the audit log of the owner operations ordered by id, paged like the ledger
+func ethAdminAudit(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	after, err := parseUintParam(q.Get("after"), 0)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid after: %v", err)
+		return
+	}
+	limit, err := parseUintParam(q.Get("limit"), 100)
+	if err != nil || limit == 0 || limit > ledgerMaxLimit {
+		writeErr(w, http.StatusBadRequest, "invalid limit, must be between 1 and %v", ledgerMaxLimit)
+		return
+	}
+
+	page := EthAdminPage{Entries: []EthAdminTx{}}
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketEthAdmin).Cursor()
+		for k, v := c.Seek(itob(after + 1)); k != nil; k, v = c.Next() {
+			if uint64(len(page.Entries)) == limit {
+				page.Next = page.Entries[len(page.Entries)-1].Id
+				break
+			}
+			var a EthAdminTx
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			page.Entries = append(page.Entries, a)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read audit log: %v", err)
+		return
+	}
+	writeJson(w, page)
+}
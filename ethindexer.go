@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	ethIndexerPollInterval = 15 * time.Second
+	// ethIndexerReorgDepth is the number of block hashes kept to detect a reorg
+	ethIndexerReorgDepth = 256
+)
+
+var bucketEthWithdrawals = []byte("eth_withdrawals")
+var bucketEthWithdrawalsUser = []byte("eth_withdrawals_user")
+var bucketEthIndexerBlocks = []byte("eth_indexer_blocks")
+
+// EthWithdrawal is a successful withdraw transaction to PayoutEth. Amount is the increase of TotalPayedOut over the
+// previous withdrawal of the user on this chain.
+type EthWithdrawal struct {
+	Chain         string         `json:"chain"`
+	ChainId       int64          `json:"chainId"`
+	Block         uint64         `json:"block"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	Time          time.Time      `json:"time"`
+	TxHash        common.Hash    `json:"txHash"`
+	TxIndex       uint64         `json:"txIndex"`
+	From          common.Address `json:"from"`
+	UserId        uuid.UUID      `json:"userId"`
+	Dev           common.Address `json:"dev"`
+	TotalPayedOut *big.Int       `json:"totalPayedOut"`
+	Amount        *big.Int       `json:"amount"`
+}
+
+// ethRpcBlock has the fields of eth_getBlockByNumber the indexer needs. The block is not decoded with ethclient, as
+// it fails on the transaction types of some networks.
+type ethRpcBlock struct {
+	Number       hexutil.Uint64 `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	ParentHash   common.Hash    `json:"parentHash"`
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Transactions []ethRpcTx     `json:"transactions"`
+}
+
+type ethRpcTx struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	Input            hexutil.Bytes   `json:"input"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+}
+
+type ethRpcReceipt struct {
+	Status    hexutil.Uint64 `json:"status"`
+	BlockHash common.Hash    `json:"blockHash"`
+}
+
+// ethIndexer stores the withdrawals of the contract, starting at the checkpoint or at the start block. The blocks
+// are polled, a reorg is rolled back up to ethIndexerReorgDepth blocks.
+func ethIndexer(c *ClientETH, start uint64) {
+	log.Printf("%v indexer: indexing %v from block %v", c.name, c.address, start)
+	for {
+		err := ethIndexBlocks(c, start)
+		if err != nil {
+			log.Errorf("%v indexer: %v", c.name, err)
+		}
+		time.Sleep(ethIndexerPollInterval)
+	}
+}
+
+// ethIndexBlocks indexes all blocks from the checkpoint to the latest block
+func ethIndexBlocks(c *ClientETH, start uint64) error {
+	head, err := c.c.BlockNumber(context.Background())
+	if err != nil {
+		return fmt.Errorf("get block number: %w", err)
+	}
+	next, prevHash, err := ethIndexerCheckpoint(c, start)
+	if err != nil {
+		return err
+	}
+	for next <= head {
+		b, err := c.blockByNumber(next)
+		if err != nil {
+			return err
+		}
+		if prevHash != nil && b.ParentHash != *prevHash {
+			log.Warnf("%v indexer: reorg at block %v, rolling back", c.name, next-1)
+			if err = ethIndexerRollback(c, start, next-1); err != nil {
+				return err
+			}
+			next, prevHash, err = ethIndexerCheckpoint(c, start)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var withdrawals []EthWithdrawal
+		for _, tx := range b.Transactions {
+			wd, err := c.withdrawal(b, tx)
+			if err != nil {
+				return err
+			}
+			if wd != nil {
+				withdrawals = append(withdrawals, *wd)
+			}
+		}
+		if err = storeEthWithdrawals(c, uint64(b.Number), b.Hash, withdrawals); err != nil {
+			return err
+		}
+		if len(withdrawals) > 0 {
+			log.Printf("%v indexer: %v withdrawals in block %v", c.name, len(withdrawals), next)
+		}
+		prevHash = &b.Hash
+		next++
+	}
+	return nil
+}
+
+func (c *ClientETH) blockByNumber(n uint64) (*ethRpcBlock, error) {
+	var b *ethRpcBlock
+	err := c.rpc.CallContext(context.Background(), &b, "eth_getBlockByNumber", hexutil.EncodeUint64(n), true)
+	if err != nil {
+		return nil, fmt.Errorf("get block %v: %w", n, err)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("block %v not found", n)
+	}
+	return b, nil
+}
+
+// withdrawal decodes a withdraw call to the contract, nil if the transaction is something else or failed
+func (c *ClientETH) withdrawal(b *ethRpcBlock, tx ethRpcTx) (*EthWithdrawal, error) {
+	if tx.To == nil || *tx.To != c.address || len(tx.Input) < 4 {
+		return nil, nil
+	}
+	parsed, err := PayoutEthMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	method, err := parsed.MethodById(tx.Input[:4])
+	if err != nil || method.Name != "withdraw" {
+		return nil, nil
+	}
+	args, err := method.Inputs.Unpack(tx.Input[4:])
+	if err != nil {
+		log.Warnf("%v indexer: could not decode withdraw %v: %v", c.name, tx.Hash, err)
+		return nil, nil
+	}
+
+	var receipt *ethRpcReceipt
+	err = c.rpc.CallContext(context.Background(), &receipt, "eth_getTransactionReceipt", tx.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("get receipt %v: %w", tx.Hash, err)
+	}
+	if receipt == nil || receipt.BlockHash != b.Hash {
+		return nil, fmt.Errorf("receipt %v is not in block %v, retrying", tx.Hash, b.Hash)
+	}
+	if receipt.Status != 1 {
+		return nil, nil
+	}
+
+	userId := args[1].([32]byte)
+	return &EthWithdrawal{
+		Chain:         c.name,
+		ChainId:       c.chainId.Int64(),
+		Block:         uint64(b.Number),
+		BlockHash:     b.Hash,
+		Time:          time.Unix(int64(b.Timestamp), 0).UTC(),
+		TxHash:        tx.Hash,
+		TxIndex:       uint64(tx.TransactionIndex),
+		From:          tx.From,
+		UserId:        uuid.UUID(bytes16(userId[:])),
+		Dev:           args[0].(common.Address),
+		TotalPayedOut: args[2].(*big.Int),
+	}, nil
+}
+
+// ethIndexerCheckpoint returns the next block to index and the hash of the last indexed block, nil if none
+func ethIndexerCheckpoint(c *ClientETH, start uint64) (uint64, *common.Hash, error) {
+	next := start
+	var prevHash *common.Hash
+	err := db.View(func(tx *bolt.Tx) error {
+		prefix := itob(uint64(c.chainId.Int64()))
+		k, v := lastWithPrefix(tx.Bucket(bucketEthIndexerBlocks).Cursor(), prefix)
+		if k != nil {
+			next = btoi(k[8:]) + 1
+			h := common.BytesToHash(v)
+			prevHash = &h
+		}
+		return nil
+	})
+	return next, prevHash, err
+}
+
+// storeEthWithdrawals stores the withdrawals and the hash of the block, which is the checkpoint. Old block hashes are
+// removed.
+func storeEthWithdrawals(c *ClientETH, block uint64, blockHash common.Hash, withdrawals []EthWithdrawal) error {
+	chainId := itob(uint64(c.chainId.Int64()))
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketEthWithdrawals)
+		bu := tx.Bucket(bucketEthWithdrawalsUser)
+		for _, wd := range withdrawals {
+			userPrefix := append(wd.UserId[:], chainId...)
+			wd.Amount = new(big.Int).Set(wd.TotalPayedOut)
+			if k, _ := lastWithPrefix(bu.Cursor(), userPrefix); k != nil {
+				var prev EthWithdrawal
+				if err := json.Unmarshal(b.Get(k[16:]), &prev); err != nil {
+					return err
+				}
+				wd.Amount.Sub(wd.Amount, prev.TotalPayedOut)
+			}
+			key := ethWithdrawalKey(chainId, block, wd.TxIndex)
+			j, err := json.Marshal(wd)
+			if err != nil {
+				return err
+			}
+			if err = b.Put(key, j); err != nil {
+				return err
+			}
+			if err = bu.Put(append(wd.UserId[:], key...), nil); err != nil {
+				return err
+			}
+		}
+
+		bb := tx.Bucket(bucketEthIndexerBlocks)
+		if err := bb.Put(append(chainId, itob(block)...), blockHash.Bytes()); err != nil {
+			return err
+		}
+		if block >= ethIndexerReorgDepth {
+			return bb.Delete(append(chainId, itob(block-ethIndexerReorgDepth)...))
+		}
+		return nil
+	})
+}
+
+// ethIndexerRollback removes the block and its withdrawals, the checkpoint moves to the block before. The hash of
+// the block before has to be known, unless the block is the start block.
+func ethIndexerRollback(c *ClientETH, start uint64, block uint64) error {
+	chainId := itob(uint64(c.chainId.Int64()))
+	return db.Update(func(tx *bolt.Tx) error {
+		bb := tx.Bucket(bucketEthIndexerBlocks)
+		key := append(chainId, itob(block)...)
+		if block > start && bb.Get(append(chainId, itob(block-1)...)) == nil {
+			return fmt.Errorf("reorg deeper than %v blocks at %v, reset the indexer", ethIndexerReorgDepth, block)
+		}
+		if err := bb.Delete(key); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(bucketEthWithdrawals)
+		bu := tx.Bucket(bucketEthWithdrawalsUser)
+		cur := b.Cursor()
+		for k, v := cur.Seek(key); k != nil && bytes.HasPrefix(k, key); k, v = cur.Seek(key) {
+			var wd EthWithdrawal
+			if err := json.Unmarshal(v, &wd); err != nil {
+				return err
+			}
+			if err := bu.Delete(append(wd.UserId[:], k...)); err != nil {
+				return err
+			}
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func ethWithdrawalKey(chainId []byte, block uint64, txIndex uint64) []byte {
+	key := make([]byte, 0, 20)
+	key = append(key, chainId...)
+	key = append(key, itob(block)...)
+	return binary.BigEndian.AppendUint32(key, uint32(txIndex))
+}
+
+// lastWithPrefix returns the last key and value with the prefix, nil if there is none
+func lastWithPrefix(c *bolt.Cursor, prefix []byte) ([]byte, []byte) {
+	end := append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, 32)...)
+	k, v := c.Seek(end)
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	if k == nil || !bytes.HasPrefix(k, prefix) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// ethPayouts returns the withdrawals of a user ordered by chain and block, chainId filters for one chain
+func ethPayouts(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid userId: %v", err)
+		return
+	}
+	prefix := userId[:]
+	if c := r.URL.Query().Get("chainId"); c != "" {
+		chainId, err := strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid chainId: %v", err)
+			return
+		}
+		prefix = append(prefix, itob(uint64(chainId))...)
+	}
+
+	withdrawals := []EthWithdrawal{}
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketEthWithdrawals)
+		c := tx.Bucket(bucketEthWithdrawalsUser).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			var wd EthWithdrawal
+			if err := json.Unmarshal(b.Get(k[16:]), &wd); err != nil {
+				return err
+			}
+			withdrawals = append(withdrawals, wd)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read payouts: %v", err)
+		return
+	}
+	writeJson(w, withdrawals)
+}
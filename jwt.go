@@ -72,11 +72,11 @@ func jwtAuthAdmin(next func(w http.ResponseWriter, r *http.Request, email string
 	}
 }
 
-func jwtAuthServer(next func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request, *TokenClaims) {
+func jwtAuthServer(next func(w http.ResponseWriter, r *http.Request, subject string)) func(http.ResponseWriter, *http.Request, *TokenClaims) {
 	return func(w http.ResponseWriter, r *http.Request, claims *TokenClaims) {
 		if claims.Subject == "ffs-server" {
-			log.Printf("Authenticated server %s\n")
-			next(w, r)
+			log.Printf("Authenticated server %s\n", claims.Subject)
+			next(w, r, claims.Subject)
 			return
 		}
 
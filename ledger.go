@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const ledgerMaxLimit = 1000
+
+// LedgerEntry is the record of an issued signature. NEO and Tezos sign for the Account on the Chain instead of a
+// userId, Owner is the account that signed it.
+type LedgerEntry struct {
+	Id        uint64         `json:"id"`
+	UserId    uuid.UUID      `json:"userId"`
+	Chain     string         `json:"chain,omitempty"`
+	Account   string         `json:"account,omitempty"`
+	Owner     string         `json:"owner,omitempty"`
+	Amount    *big.Int       `json:"amount"`
+	Increase  *big.Int       `json:"increase"`
+	Address   common.Address `json:"address"`
+	Hash      common.Hash    `json:"hash"`
+	Signer    common.Address `json:"signer"`
+	SignMode  string         `json:"signMode"`
+	ChainId   int64          `json:"chainId,omitempty"`
+	Subject   string         `json:"subject"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type LedgerPage struct {
+	Entries []LedgerEntry `json:"entries"`
+	Next    uint64        `json:"next,omitempty"`
+}
+
+// ErrAmountDecreased is returned if a signature is requested for a lower amount than already signed
+type ErrAmountDecreased struct {
+	Requested *big.Int
+	Signed    *big.Int
+}
+
+func (e *ErrAmountDecreased) Error() string {
+	return fmt.Sprintf("ERR-02,amount %v is lower than the already signed amount %v", e.Requested, e.Signed)
+}
+
+// ErrNoNewFunds is returned if the amount is not above what the contract already payed out, the withdrawal would
+// revert with "No new funds to be withdrawn"
+type ErrNoNewFunds struct {
+	Requested *big.Int
+	PayedOut  *big.Int
+}
+
+func (e *ErrNoNewFunds) Error() string {
+	return fmt.Sprintf("ERR-03,amount %v is not above the already payed out amount %v", e.Requested, e.PayedOut)
+}
+
+// signAndRecord signs the payout and records it in the ledger. The amount has to be above the amount payed out
+// on-chain and at least the highest amount already signed for this userId on this chain. Approved is set if the
+// admins approved this signature.
+//
+// The entry is reserved in a first transaction with the checks and the policy, and removed again if the signer fails.
+// The signer, e.g., Clef waiting for a confirmation, is called outside of any transaction, so it does not block the
+// other writes to the database. Signatures of the same user and chain are serialized, so the reservation can be
+// rolled back.
+func signAndRecord(c *ClientETH, userId uuid.UUID, amount *big.Int, dev common.Address, subject string, approved bool) (*Signature, error) {
+	if amount == nil || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %v", amount)
+	}
+	if c == nil {
+		return nil, errors.New("ETH is not enabled")
+	}
+	if err := c.checkVerified(); err != nil {
+		return nil, err
+	}
+	key := ledgerMaxKey(c, userId)
+	unlock := lockLedgerKey(key)
+	defer unlock()
+
+	payedOut, err := c.payedOut(userIdBytes32(userId))
+	if err != nil {
+		return nil, fmt.Errorf("could not read payed out amount: %w", err)
+	}
+	if amount.Cmp(payedOut) <= 0 {
+		return nil, &ErrNoNewFunds{Requested: amount, PayedOut: payedOut}
+	}
+	if c.signMode != SignModeLegacy && dev == (common.Address{}) {
+		return nil, fmt.Errorf("payout address is required in sign mode %v", c.signMode)
+	}
+	hashRaw, _, err := payoutHash(c, userId, amount, dev)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &LedgerEntry{
+		UserId:   userId,
+		Amount:   amount,
+		Address:  dev,
+		Hash:     common.BytesToHash(hashRaw),
+		Signer:   c.fromAddress,
+		SignMode: c.signMode,
+		ChainId:  c.chainId.Int64(),
+		Subject:  subject,
+	}
+	prevMax, err := reserveLedgerEntry(key, e, payedOut, approved)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signPayout(c, userId, amount, dev)
+	if err != nil {
+		if errRollback := rollbackLedgerEntry(e.Id, key, prevMax); errRollback != nil {
+			log.Printf("could not remove ledger entry %v of the failed signature: %v", e.Id, errRollback)
+		}
+		return nil, err
+	}
+	sig.Claimable = new(big.Int).Sub(amount, payedOut)
+	return sig, nil
+}
+
+// signAccountAndRecord is signAndRecord for NEO and Tezos, which sign for e.Account on e.Chain. The amount has to be
+// above the amount paidOut reads from the contract and at least the highest amount already signed for the account.
+// sign is called with the reserved entry and creates the signature.
+func signAccountAndRecord(e *LedgerEntry, approved bool, paidOut func() (*big.Int, error), sign func() error) (*big.Int, error) {
+	if e.Amount == nil || e.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: invalid amount %v", errInvalidRequest, e.Amount)
+	}
+	key := ledgerAccountKey(e.Chain, e.Account)
+	unlock := lockLedgerKey(key)
+	defer unlock()
+
+	payedOut, err := paidOut()
+	if err != nil {
+		return nil, fmt.Errorf("could not read payed out amount: %w", err)
+	}
+	if e.Amount.Cmp(payedOut) <= 0 {
+		return nil, &ErrNoNewFunds{Requested: e.Amount, PayedOut: payedOut}
+	}
+	prevMax, err := reserveLedgerEntry(key, e, payedOut, approved)
+	if err != nil {
+		return nil, err
+	}
+	if err = sign(); err != nil {
+		if errRollback := rollbackLedgerEntry(e.Id, key, prevMax); errRollback != nil {
+			log.Printf("could not remove ledger entry %v of the failed signature: %v", e.Id, errRollback)
+		}
+		return nil, err
+	}
+	return new(big.Int).Sub(e.Amount, payedOut), nil
+}
+
+// reserveLedgerEntry checks the amount of the entry against the highest signed amount under the key and the policy,
+// and stores the entry with its id and increase. It returns the previous highest amount for the rollback.
+func reserveLedgerEntry(key []byte, e *LedgerEntry, payedOut *big.Int, approved bool) ([]byte, error) {
+	var prevMax []byte
+	err := db.Update(func(tx *bolt.Tx) error {
+		maxB := tx.Bucket(bucketLedgerMax)
+		base := payedOut
+		if v := maxB.Get(key); v != nil {
+			signed := new(big.Int).SetBytes(v)
+			if e.Amount.Cmp(signed) < 0 {
+				return &ErrAmountDecreased{Requested: e.Amount, Signed: signed}
+			}
+			if signed.Cmp(base) > 0 {
+				base = signed
+			}
+			prevMax = append([]byte{}, v...)
+		}
+		e.Increase = new(big.Int).Sub(e.Amount, base)
+
+		err := policy.check(tx, e, approved)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(bucketLedger)
+		e.Id, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.CreatedAt = timeNow()
+		j, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err = b.Put(itob(e.Id), j); err != nil {
+			return err
+		}
+		return maxB.Put(key, e.Amount.Bytes())
+	})
+	return prevMax, err
+}
+
+// rollbackLedgerEntry removes the reserved entry and restores the highest signed amount of the user
+func rollbackLedgerEntry(id uint64, key []byte, prevMax []byte) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketLedger).Delete(itob(id)); err != nil {
+			return err
+		}
+		maxB := tx.Bucket(bucketLedgerMax)
+		if prevMax == nil {
+			return maxB.Delete(key)
+		}
+		return maxB.Put(key, prevMax)
+	})
+}
+
+type ledgerLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	ledgerLocksMu sync.Mutex
+	ledgerLocks   = map[string]*ledgerLock{}
+)
+
+// lockLedgerKey locks the ledger of one user on one chain and returns the function to unlock it
+func lockLedgerKey(key []byte) func() {
+	ledgerLocksMu.Lock()
+	l, ok := ledgerLocks[string(key)]
+	if !ok {
+		l = &ledgerLock{}
+		ledgerLocks[string(key)] = l
+	}
+	l.refs++
+	ledgerLocksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		ledgerLocksMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(ledgerLocks, string(key))
+		}
+		ledgerLocksMu.Unlock()
+	}
+}
+
+// ledgerMaxKey is the key of the highest signed amount, the ETH chain keeps the userId as key as before
+func ledgerMaxKey(c *ClientETH, userId uuid.UUID) []byte {
+	key := userIdBytes32(userId)
+	if c == ethClient {
+		return key[:]
+	}
+	return append(key[:], itob(uint64(c.chainId.Int64()))...)
+}
+
+// ledgerAccountKey is the key of the highest signed amount of an account on NEO or Tezos
+func ledgerAccountKey(chain string, account string) []byte {
+	return []byte(chain + "/" + account)
+}
+
+// ledgerChainId returns the chain id of the entry, entries without one were signed for the ETH chain. NEO and Tezos
+// entries have no chain id.
+func ledgerChainId(e *LedgerEntry) int64 {
+	if e.Chain != "" {
+		return 0
+	}
+	if e.ChainId == 0 && ethClient != nil {
+		return ethClient.chainId.Int64()
+	}
+	return e.ChainId
+}
+
+// ledger returns the issued signatures ordered by id. Use the query parameter after with the returned next value
+// to get the next page, limit sets the page size, userId filters for one user and chainId for one chain. chain and
+// account filter the NEO and Tezos entries.
+func ledger(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	after, err := parseUintParam(q.Get("after"), 0)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid after: %v", err)
+		return
+	}
+	limit, err := parseUintParam(q.Get("limit"), 100)
+	if err != nil || limit == 0 || limit > ledgerMaxLimit {
+		writeErr(w, http.StatusBadRequest, "invalid limit, must be between 1 and %v", ledgerMaxLimit)
+		return
+	}
+	var userId *uuid.UUID
+	if u := q.Get("userId"); u != "" {
+		id, err := uuid.Parse(u)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid userId: %v", err)
+			return
+		}
+		userId = &id
+	}
+	var chainId int64
+	if c := q.Get("chainId"); c != "" {
+		chainId, err = strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid chainId: %v", err)
+			return
+		}
+	}
+
+	chain := q.Get("chain")
+	account := q.Get("account")
+
+	page := LedgerPage{Entries: []LedgerEntry{}}
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketLedger).Cursor()
+		for k, v := c.Seek(itob(after + 1)); k != nil; k, v = c.Next() {
+			var e LedgerEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if userId != nil && e.UserId != *userId {
+				continue
+			}
+			if chainId != 0 && ledgerChainId(&e) != chainId {
+				continue
+			}
+			if (chain != "" && e.Chain != chain) || (account != "" && e.Account != account) {
+				continue
+			}
+			if uint64(len(page.Entries)) == limit {
+				page.Next = page.Entries[len(page.Entries)-1].Id
+				break
+			}
+			page.Entries = append(page.Entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read ledger: %v", err)
+		return
+	}
+	writeJson(w, page)
+}
+
+func parseUintParam(s string, defaultValue uint64) (uint64, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingSigner waits for release before it signs a hash, and fails if err is set
+type blockingSigner struct {
+	Signer
+	signing chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingSigner) SignHash(hash []byte) ([]byte, error) {
+	b.signing <- struct{}{}
+	<-b.release
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.Signer.SignHash(hash)
+}
+
+func newLedgerTestClient(t *testing.T) (*ClientETH, *blockingSigner) {
+	t.Helper()
+	var err error
+	db, err = openDB(filepath.Join(t.TempDir(), "payout.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	c, _ := newSimulatedEthClient(t, SignModeBound, payoutEthBins[SignModeBound])
+	s := &blockingSigner{Signer: c.signer, signing: make(chan struct{}), release: make(chan struct{})}
+	c.signer = s
+	return c, s
+}
+
+func ledgerMax(t *testing.T, c *ClientETH, userId uuid.UUID) []byte {
+	t.Helper()
+	var v []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		v = tx.Bucket(bucketLedgerMax).Get(ledgerMaxKey(c, userId))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSignAndRecordSignsOutsideTransaction(t *testing.T) {
+	c, s := newLedgerTestClient(t)
+	userId := uuid.New()
+	dev := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+	done := make(chan error)
+	go func() {
+		_, err := signAndRecord(c, userId, big.NewInt(100), dev, "test", false)
+		done <- err
+	}()
+	<-s.signing
+
+	//the database is writable while the signer waits
+	written := make(chan error)
+	go func() {
+		written <- saveEthAdminTx(&EthAdminTx{Method: "test"})
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		close(s.release)
+		<-done
+		t.Fatal("database write blocked by the signer")
+	}
+
+	close(s.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if v := ledgerMax(t, c, userId); new(big.Int).SetBytes(v).Int64() != 100 {
+		t.Fatalf("highest signed amount is %v, expected 100", new(big.Int).SetBytes(v))
+	}
+}
+
+func TestSignAndRecordRollsBackFailedSignature(t *testing.T) {
+	c, s := newLedgerTestClient(t)
+	userId := uuid.New()
+	dev := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+	s.err = errors.New("rejected")
+	close(s.release)
+	go func() {
+		for range s.signing {
+		}
+	}()
+	t.Cleanup(func() { close(s.signing) })
+
+	if _, err := signAndRecord(c, userId, big.NewInt(100), dev, "test", false); err == nil {
+		t.Fatal("expected the signer error")
+	}
+	if v := ledgerMax(t, c, userId); v != nil {
+		t.Fatalf("highest signed amount is %v after the failed signature", new(big.Int).SetBytes(v))
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(bucketLedger).Cursor().First(); k != nil {
+			return errors.New("ledger entry of the failed signature was kept")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumeApprovals(t *testing.T) {
+	_, s := newLedgerTestClient(t)
+	close(s.release)
+	go func() {
+		for range s.signing {
+		}
+	}()
+	t.Cleanup(func() { close(s.signing) })
+
+	//the server stopped after the last approval, before the signature was stored
+	a := &Approval{
+		Id:      uuid.New(),
+		UserId:  uuid.New(),
+		Amount:  big.NewInt(100),
+		Address: common.HexToAddress("0x00000000000000000000000000000000000000d1"),
+		Status:  ApprovalSigning,
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		return putApproval(tx, a)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumeApprovals()
+	err = db.View(func(tx *bolt.Tx) error {
+		a, err = getApproval(tx, a.Id)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != ApprovalApproved || a.Signature == nil {
+		t.Fatalf("approval is %v: %v", a.Status, a.Error)
+	}
+}
+
+func TestSignAccountAndRecord(t *testing.T) {
+	var err error
+	db, err = openDB(filepath.Join(t.TempDir(), "payout.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	policy = &Policy{Denylist: []string{"denied"}}
+	t.Cleanup(func() { policy = nil })
+
+	paidOut := func() (*big.Int, error) { return big.NewInt(50), nil }
+	signed := func() error { return nil }
+	entry := func(account string, amount int64) *LedgerEntry {
+		return &LedgerEntry{Chain: "neo", Account: account, Amount: big.NewInt(amount), Subject: "test"}
+	}
+
+	var errPolicy *ErrPolicy
+	if _, err = signAccountAndRecord(entry("denied", 100), false, paidOut, signed); !errors.As(err, &errPolicy) {
+		t.Fatalf("expected the denylist, got %v", err)
+	}
+	var errNoNewFunds *ErrNoNewFunds
+	if _, err = signAccountAndRecord(entry("a", 50), false, paidOut, signed); !errors.As(err, &errNoNewFunds) {
+		t.Fatalf("expected no new funds, got %v", err)
+	}
+	e := entry("a", 100)
+	claimable, err := signAccountAndRecord(e, false, paidOut, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimable.Int64() != 50 || e.Increase.Int64() != 50 || e.Id == 0 {
+		t.Fatalf("unexpected claimable %v or entry %+v", claimable, e)
+	}
+
+	_, err = signAccountAndRecord(entry("a", 120), false, paidOut, func() error { return errors.New("sign failed") })
+	if err == nil {
+		t.Fatal("expected the sign error")
+	}
+	var errDecreased *ErrAmountDecreased
+	if _, err = signAccountAndRecord(entry("a", 90), false, paidOut, signed); !errors.As(err, &errDecreased) || errDecreased.Signed.Int64() != 100 {
+		t.Fatalf("expected the signed amount 100 after the rollback, got %v", err)
+	}
+	//the same account on another chain has its own ledger
+	if _, err = signAccountAndRecord(&LedgerEntry{Chain: "xtz", Account: "a", Amount: big.NewInt(90)}, false, paidOut, signed); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,26 +2,38 @@ package main
 
 import (
 	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/dimiro1/banner"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/wallet"
 	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Signature struct {
-	Raw  []byte   `json:"raw"`
-	Hash [32]byte `json:"hash"`
-	R    [32]byte `json:"r"`
-	S    [32]byte `json:"s"`
-	V    uint8    `json:"v"`
+	Raw       []byte              `json:"raw"`
+	Hash      [32]byte            `json:"hash"`
+	R         [32]byte            `json:"r"`
+	S         [32]byte            `json:"s"`
+	V         uint8               `json:"v"`
+	TypedData *apitypes.TypedData `json:"typedData,omitempty"`
+	Dev       common.Address      `json:"dev"`
+	SignMode  string              `json:"signMode"`
+	ChainId   int64               `json:"chainId"`
+	Claimable *big.Int            `json:"claimable,omitempty"`
 }
 
 type Timewarp struct {
@@ -35,20 +47,66 @@ type Blockchain struct {
 	Deploy     bool
 }
 
+// EthChain is an additional EVM network from ETH_CHAINS, the signer and sign mode default to the ETH values
+type EthChain struct {
+	Name                 string
+	Blockchain           Blockchain
+	SignMode             string
+	Keystore             string
+	KeystorePasswordFile string
+	IndexerStart         int
+}
+
 type Opts struct {
-	Port     int
-	Env      string
-	HS256    string
-	Ethereum Blockchain
-	NEO      Blockchain
-	Admins   string
+	Port                    int
+	Env                     string
+	HS256                   string
+	Ethereum                Blockchain
+	EthSignMode             string
+	EthSigner               string
+	EthKeystore             string
+	EthKeystorePasswordFile string
+	EthExternalSigner       string
+	EthSignerAddress        string
+	EthCodeHashes           string
+	EthChainNames           string
+	EthIndexer              bool
+	EthIndexerStart         int
+	EthChains               []EthChain
+	NEO                     Blockchain
+	XTZ                     Blockchain
+	NeoNetwork              int
+	NeoChunkSize            int
+	NeoMaxGasPerTx          int64
+	NeoPayoutMap            bool
+	NeoIndexer              bool
+	NeoStateFile            string
+	NeoWallet               string
+	NeoWalletPasswordFile   string
+	NeoWalletAccount        string
+	NeoWsUrl                string
+	NeoTimewarpMethod       string
+	NeoIndexerStart         int
+	NeoIndexerTokens        string
+	Chains                  string
+	Admins                  string
+	DBPath                  string
+	PolicyFile              string
+	ApprovalsRequired       int
+	ApprovalTTLHours        int
 }
 
 var (
 	opts       *Opts
 	jwtKey     []byte
 	ethClient  *ClientETH
+	ethClients = map[int64]*ClientETH{}
+	ethSigner  Signer
+	db         *bolt.DB
+	policy     *Policy
 	neoClient  *neo.Client
+	xtzClient  *ClientXTZ
+	neoOwners  []*wallet.Account
 	debug      bool
 	secondsAdd int
 	admins     []string
@@ -70,11 +128,51 @@ func NewOpts() *Opts {
 	flag.StringVar(&o.Ethereum.Contract, "eth-contract", lookupEnv("ETH_CONTRACT"), "Ethereum contract address")
 	flag.StringVar(&o.Ethereum.Url, "eth-url", lookupEnv("ETH_URL"), "Ethereum URL")
 	flag.BoolVar(&o.Ethereum.Deploy, "eth-deploy", lookupEnv("ETH_DEPLOY") == "true", "Set to true to deploy ETH contract")
+	flag.StringVar(&o.EthSignMode, "eth-sign-mode", lookupEnv("ETH_SIGN_MODE", SignModeBound), "ETH signing scheme, bound (PayoutEthBound.sol), eip712 (PayoutEthEip712.sol) or legacy (PayoutEth.sol)")
+	flag.StringVar(&o.EthSigner, "eth-signer", lookupEnv("ETH_SIGNER", SignerKey), "ETH signer, key (local only), keystore or external")
+	flag.StringVar(&o.EthKeystore, "eth-keystore", lookupEnv("ETH_KEYSTORE"), "ETH encrypted JSON keystore file")
+	flag.StringVar(&o.EthKeystorePasswordFile, "eth-keystore-password-file", lookupEnv("ETH_KEYSTORE_PASSWORD_FILE"), "File with the password of the ETH keystore")
+	flag.StringVar(&o.EthExternalSigner, "eth-external-signer", lookupEnv("ETH_EXTERNAL_SIGNER"), "Clef compatible external signer endpoint")
+	flag.StringVar(&o.EthSignerAddress, "eth-signer-address", lookupEnv("ETH_SIGNER_ADDRESS"), "Account of the external signer, first account if empty")
+	flag.StringVar(&o.EthCodeHashes, "eth-code-hashes", lookupEnv("ETH_CODE_HASHES"), "Comma separated keccak256 hashes of allowed contract codes besides the embedded contracts")
+	flag.BoolVar(&o.EthIndexer, "eth-indexer", lookupEnv("ETH_INDEXER") == "true", "Set to true to index the withdrawals of the EVM contracts")
+	flag.IntVar(&o.EthIndexerStart, "eth-indexer-start", lookupEnvInt("ETH_INDEXER_START"), "First block to index, e.g., the block the ETH contract was deployed")
+	flag.StringVar(&o.EthChainNames, "eth-chains", lookupEnv("ETH_CHAINS"), "Comma separated names of additional EVM chains, configured with ETH_<NAME>_URL etc.")
 	flag.StringVar(&o.NEO.PrivateKey, "neo-private-key", lookupEnv("NEO_PRIVATE_KEY"), "NEO private key")
+	flag.StringVar(&o.NeoWallet, "neo-wallet", lookupEnv("NEO_WALLET"), "NEP-6 wallet with the NEO owner account, NEO_PRIVATE_KEY is used if empty")
+	flag.StringVar(&o.NeoWalletPasswordFile, "neo-wallet-password-file", lookupEnv("NEO_WALLET_PASSWORD_FILE"), "File with the password of the NEO wallet")
+	flag.StringVar(&o.NeoWalletAccount, "neo-wallet-account", lookupEnv("NEO_WALLET_ACCOUNT"), "Address of the NEO owner account in the wallet, the default account if empty")
 	flag.StringVar(&o.NEO.Contract, "neo-contract", lookupEnv("NEO_CONTRACT"), "NEO contract address")
 	flag.StringVar(&o.NEO.Url, "neo-url", lookupEnv("NEO_URL"), "NEO URL")
 	flag.BoolVar(&o.NEO.Deploy, "neo-deploy", lookupEnv("NEO_DEPLOY") == "true", "Set to true to deploy NEO contract")
+	flag.StringVar(&o.XTZ.PrivateKey, "xtz-private-key", lookupEnv("XTZ_PRIVATE_KEY"), "Tezos private key, edsk or spsk")
+	flag.StringVar(&o.XTZ.Contract, "xtz-contract", lookupEnv("XTZ_CONTRACT"), "Tezos contract address")
+	flag.StringVar(&o.XTZ.Url, "xtz-url", lookupEnv("XTZ_URL"), "Tezos RPC URL, Tezos is disabled if empty")
+	flag.BoolVar(&o.XTZ.Deploy, "xtz-deploy", lookupEnv("XTZ_DEPLOY") == "true", "Set to true to deploy Tezos contract")
+	flag.IntVar(&o.NeoNetwork, "neo-network", lookupEnvInt("NEO_NETWORK"), "Expected NEO network magic, not checked if 0")
+	flag.IntVar(&o.NeoChunkSize, "neo-chunk-size", lookupEnvInt("NEO_CHUNK_SIZE", 100), "Maximum number of recipients per NEO batchPayout transaction")
+	flag.Int64Var(&o.NeoMaxGasPerTx, "neo-max-gas-per-tx", int64(lookupEnvInt("NEO_MAX_GAS_PER_TX")), "Maximum system and network fee per NEO transaction in GAS fractions (1e-8), not checked if 0")
+	flag.BoolVar(&o.NeoPayoutMap, "neo-payout-map", lookupEnv("NEO_PAYOUT_MAP") == "true", "Set to true to use batchPayout(Map) instead of batchPayout(Array, Array)")
+	flag.StringVar(&o.NeoStateFile, "neo-state-file", lookupEnv("NEO_STATE_FILE", "neo-state.json"), "File with the hash of the deployed NEO contract")
+	flag.BoolVar(&o.NeoIndexer, "neo-indexer", lookupEnv("NEO_INDEXER") == "true", "Set to true to index the events of the NEO contract")
+	flag.StringVar(&o.NeoWsUrl, "neo-ws-url", lookupEnv("NEO_WS_URL"), "NEO WebSocket URL for new blocks, blocks are polled if empty")
+	flag.IntVar(&o.NeoIndexerStart, "neo-indexer-start", lookupEnvInt("NEO_INDEXER_START"), "First block to index, e.g., the block the contract was deployed")
+	flag.StringVar(&o.NeoIndexerTokens, "neo-indexer-tokens", lookupEnv("NEO_INDEXER_TOKENS"), "Comma separated NEP-17 contract hashes whose transfers are indexed besides GAS")
+	flag.StringVar(&o.NeoTimewarpMethod, "neo-timewarp-method", lookupEnv("NEO_TIMEWARP_METHOD"), "RPC method of the NEO privnet to produce blocks for the time warp, NEO is not warped if empty")
+	//Tezos used to be enabled by XTZ_URL alone
+	defaultChains := "eth,neo"
+	if o.XTZ.Url != "" {
+		defaultChains += ",xtz"
+	}
+	if o.EthChainNames != "" {
+		defaultChains += "," + o.EthChainNames
+	}
+	flag.StringVar(&o.Chains, "chains", lookupEnv("CHAINS", defaultChains), "Comma separated chains to enable: eth, neo, xtz and the names of ETH_CHAINS")
 	flag.StringVar(&o.Admins, "admins", lookupEnv("ADMINS"), "Admins")
+	flag.StringVar(&o.PolicyFile, "policy-file", lookupEnv("POLICY_FILE"), "JSON file with the signing policy, no policy if empty")
+	flag.IntVar(&o.ApprovalsRequired, "approvals-required", lookupEnvInt("APPROVALS_REQUIRED", 2), "Number of distinct admins that have to approve a signature above the approval threshold")
+	flag.IntVar(&o.ApprovalTTLHours, "approval-ttl-hours", lookupEnvInt("APPROVAL_TTL_HOURS", 72), "Hours until a pending approval expires")
+	flag.StringVar(&o.DBPath, "db-path", lookupEnv("DB_PATH", "payout.db"), "Path of the database file, e.g., for the signature ledger")
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
@@ -100,14 +198,87 @@ func NewOpts() *Opts {
 	}
 
 	admins = strings.Split(o.Admins, ";")
+	if o.ApprovalsRequired > len(admins) {
+		log.Warnf("APPROVALS_REQUIRED %v is more than the %v admins, approvals cannot be completed", o.ApprovalsRequired, len(admins))
+	}
+
+	if o.NeoChunkSize < 1 {
+		log.Fatalf("NEO_CHUNK_SIZE must be at least 1, is %v", o.NeoChunkSize)
+	}
+
+	checkSignMode("eth", o.EthSignMode)
 
 	if strings.HasPrefix(o.Ethereum.PrivateKey, "0x") {
 		o.Ethereum.PrivateKey = o.Ethereum.PrivateKey[2:]
 	}
 
+	for _, h := range strings.Split(o.EthCodeHashes, ",") {
+		if h = strings.TrimSpace(h); h != "" && len(common.FromHex(h)) != common.HashLength {
+			log.Fatalf("Invalid code hash %v in ETH_CODE_HASHES", h)
+		}
+	}
+
+	o.EthChains = ethChainOpts(o)
+
 	return o
 }
 
+func checkSignMode(name string, signMode string) {
+	switch signMode {
+	case SignModeBound, SignModeEip712:
+	case SignModeLegacy:
+		log.Printf("%v sign mode %v: signatures are not bound to the recipient address", name, signMode)
+	default:
+		log.Fatalf("Unknown %v sign mode %v", name, signMode)
+	}
+}
+
+var ethChainName = regexp.MustCompile("^[a-z][a-z0-9]*$")
+
+// ethChainOpts reads the additional EVM chains, e.g., for ETH_CHAINS=polygon from ETH_POLYGON_URL,
+// ETH_POLYGON_CONTRACT, ETH_POLYGON_DEPLOY, ETH_POLYGON_SIGN_MODE and the optional signer ETH_POLYGON_KEYSTORE,
+// ETH_POLYGON_KEYSTORE_PASSWORD_FILE or ETH_POLYGON_PRIVATE_KEY, and ETH_POLYGON_INDEXER_START
+func ethChainOpts(o *Opts) []EthChain {
+	var ret []EthChain
+	if o.EthChainNames == "" {
+		return ret
+	}
+	for _, name := range strings.Split(o.EthChainNames, ",") {
+		name = strings.TrimSpace(name)
+		if !ethChainName.MatchString(name) {
+			log.Fatalf("Invalid ETH chain name %v, use lowercase letters and digits", name)
+		}
+		if _, ok := chainFactories[name]; ok {
+			log.Fatalf("ETH chain name %v is already used", name)
+		}
+		for _, c := range ret {
+			if c.Name == name {
+				log.Fatalf("ETH chain %v is listed twice", name)
+			}
+		}
+		prefix := "ETH_" + strings.ToUpper(name) + "_"
+		c := EthChain{
+			Name: name,
+			Blockchain: Blockchain{
+				Url:        lookupEnv(prefix + "URL"),
+				Contract:   lookupEnv(prefix + "CONTRACT"),
+				PrivateKey: strings.TrimPrefix(lookupEnv(prefix+"PRIVATE_KEY"), "0x"),
+				Deploy:     lookupEnv(prefix+"DEPLOY") == "true",
+			},
+			SignMode:             lookupEnv(prefix+"SIGN_MODE", o.EthSignMode),
+			Keystore:             lookupEnv(prefix + "KEYSTORE"),
+			KeystorePasswordFile: lookupEnv(prefix + "KEYSTORE_PASSWORD_FILE"),
+			IndexerStart:         lookupEnvInt(prefix + "INDEXER_START"),
+		}
+		if c.Blockchain.Url == "" {
+			log.Fatalf("%vURL is required for ETH chain %v", prefix, name)
+		}
+		checkSignMode(name, c.SignMode)
+		ret = append(ret, c)
+	}
+	return ret
+}
+
 func lookupEnv(key string, defaultValues ...string) string {
 	if val, ok := os.LookupEnv(key); ok {
 		return val
@@ -137,31 +308,44 @@ func lookupEnvInt(key string, defaultValues ...int) int {
 	return 0
 }
 
-func ethInit() *ClientETH {
+func ethInit(ec EthChain, signer Signer) (*ClientETH, error) {
 	now := time.Now()
-	ethClient, err := getEthClient(opts.Ethereum.Url, opts.Ethereum.PrivateKey, opts.Ethereum.Deploy, opts.Ethereum.Contract)
-	for err != nil && now.Add(time.Duration(10)*time.Second).After(time.Now()) {
+	ethClient, err := getEthClient(ec, signer)
+	for err != nil && !errors.Is(err, errEthDeploy) && !errors.Is(err, errChainConfig) && now.Add(time.Duration(10)*time.Second).After(time.Now()) {
 		time.Sleep(time.Second)
-		ethClient, err = getEthClient(opts.Ethereum.Url, opts.Ethereum.PrivateKey, opts.Ethereum.Deploy, opts.Ethereum.Contract)
+		ethClient, err = getEthClient(ec, signer)
 	}
 	if err != nil {
-		log.Fatal("Could not initialize ETH network", err)
+		return nil, fmt.Errorf("could not initialize %v network: %w", ec.Name, err)
 	}
-	return ethClient
+	return ethClient, nil
 }
 
-func neoInit() *neo.Client {
+// neoInit retries for 10s, except for configuration errors, which do not go away by retrying
+func neoInit() (*neo.Client, error) {
 	now := time.Now()
 	neoClient, err := getNeoClient(opts.NEO.Url)
-	for err != nil && now.Add(time.Duration(10)*time.Second).After(time.Now()) {
+	for err != nil && !isNeoConfigErr(err) && now.Add(time.Duration(10)*time.Second).After(time.Now()) {
 		time.Sleep(time.Second)
 		neoClient, err = getNeoClient(opts.NEO.Url)
 	}
 	if err != nil {
-		//log.Fatal("Could not initialize NEO network", err)
-		log.Debugf("Could not initialize NEO network", err)
+		return nil, fmt.Errorf("could not initialize NEO network: %w", err)
+	}
+	return neoClient, nil
+}
+
+func xtzInit() *ClientXTZ {
+	if opts.XTZ.Url == "" {
+		return nil
+	}
+	xtzClient, err := getXtzClient(opts.XTZ.Url, opts.XTZ.PrivateKey, opts.XTZ.Contract, opts.XTZ.Deploy)
+	if err != nil {
+		log.Warnf("Could not initialize Tezos network: %v", err)
+		return nil
 	}
-	return neoClient
+	opts.XTZ.Contract = xtzClient.contract
+	return xtzClient
 }
 
 func timeNow() time.Time {
@@ -182,14 +366,51 @@ func main() {
 
 	opts = NewOpts()
 
-	ethClient = ethInit()
-	neoClient = neoInit()
+	db, err = openDB(opts.DBPath)
+	if err != nil {
+		log.Fatalf("Could not open database %v: %v", opts.DBPath, err)
+	}
+	defer db.Close()
+
+	policy, err = loadPolicy(opts.PolicyFile)
+	if err != nil {
+		log.Fatalf("Could not load policy: %v", err)
+	}
+
+	ethSigner, err = newSigner(opts)
+	if err != nil {
+		log.Fatalf("Could not initialize ETH signer: %v", err)
+	}
+
+	initChains(opts)
+	go resumeApprovals()
 
 	// only internal routes, not accessible through caddy server
 	router := mux.NewRouter()
 	//this can only be called by an internal server
+	router.HandleFunc("/admin/sign/batch", jwtAuth(jwtAuthServer(signBatch))).Methods(http.MethodPost)
 	router.HandleFunc("/admin/sign/{userId}/{totalPayedOut}", jwtAuth(jwtAuthServer(sign))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/payout/neo", jwtAuth(jwtAuthServer(neoPayout))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/payout/neo/{id}", jwtAuth(jwtAuthServer(neoPayoutJob))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/neo/tea", jwtAuth(jwtAuthServer(neoTeaSet))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/neo/tea/{address}", jwtAuth(jwtAuthServer(neoTeaGet))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/neo/events/{address}", jwtAuth(jwtAuthServer(neoEvents))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/chain/{chain}/sign", jwtAuth(jwtAuthServer(chainSign))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/chain/{chain}/paid", jwtAuth(jwtAuthServer(chainPaidOut))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/chain/{chain}/payout", jwtAuth(jwtAuthServer(chainPayout))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/sign/approval/{id}", jwtAuth(jwtAuthServer(approvalGet))).Methods(http.MethodGet)
 	//this can be called from frontend, but only the admin
+	router.HandleFunc("/admin/neo/cosign", jwtAuth(jwtAuthAdmin(neoCosigns, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/neo/cosign/{txHash}", jwtAuth(jwtAuthAdmin(neoCosignGet, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/neo/cosign/{txHash}", jwtAuth(jwtAuthAdmin(neoCosignPost, admins))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/ledger", jwtAuth(jwtAuthAdmin(ledger, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/eth/owner", jwtAuth(jwtAuthAdmin(ethChangeOwner, admins))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/eth/recover/eth", jwtAuth(jwtAuthAdmin(ethRecoverEth, admins))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/eth/recover/token", jwtAuth(jwtAuthAdmin(ethRecoverToken, admins))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/eth/audit", jwtAuth(jwtAuthAdmin(ethAdminAudit, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/approvals", jwtAuth(jwtAuthAdmin(approvals, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/approvals/{id}", jwtAuth(jwtAuthAdmin(approvalGet, admins))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/approvals/{id}", jwtAuth(jwtAuthAdmin(approvalPost, admins))).Methods(http.MethodPost)
 	if debug {
 		router.HandleFunc("/admin/time", jwtAuth(jwtAuthAdmin(serverTime, admins))).Methods(http.MethodGet)
 		router.HandleFunc("/admin/time/eth", jwtAuth(jwtAuthAdmin(serverTimeEth, admins))).Methods(http.MethodGet)
@@ -197,6 +418,8 @@ func main() {
 	}
 	//available for the public
 	router.HandleFunc("/config", config).Methods(http.MethodGet)
+	router.HandleFunc("/verify", verify).Methods(http.MethodPost)
+	router.HandleFunc("/payouts/{userId}", ethPayouts).Methods(http.MethodGet)
 
 	log.Printf("listing on port %v", opts.Port)
 	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(opts.Port), router))
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/nspcc-dev/neo-go/pkg/config/netmode"
 	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
 	"github.com/nspcc-dev/neo-go/pkg/core/state"
 	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
 	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
 	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/bigint"
 	"github.com/nspcc-dev/neo-go/pkg/io"
 	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
@@ -18,10 +22,15 @@ import (
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract/nef"
 	"github.com/nspcc-dev/neo-go/pkg/util"
 	"github.com/nspcc-dev/neo-go/pkg/vm/emit"
+	"github.com/nspcc-dev/neo-go/pkg/vm/opcode"
 	"github.com/nspcc-dev/neo-go/pkg/wallet"
 	"io/ioutil"
 	"log"
 	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 )
 
 func getNeoClient(endpoint string) (*neo.Client, error) {
@@ -36,84 +45,296 @@ func getNeoClient(endpoint string) (*neo.Client, error) {
 		return nil, err
 	}
 
-	contractOwnerPrivateKey, err := keys.NewPrivateKeyFromWIF(opts.NEO.PrivateKey)
-	if err != nil {
-		return nil, err
+	if len(neoOwners) == 0 {
+		return nil, &NeoError{Code: NeoErrConfig, Err: errors.New("NEO owner is not loaded")}
 	}
-	// signatureBytes := signature_provider.NewSignatureNeo(dev, tea, contractOwnerPrivateKey)
-
-	// Following the steps on the developer's side after receiving the signature bytes:
-	// Create and initialize client
-	// Developer received the signature bytes and can now create the transaction to withdraw funds
-	owner := wallet.NewAccountFromPrivateKey(contractOwnerPrivateKey)
+	owner := neoOwners[0]
 
 	if opts.NEO.Deploy {
-		h, err := deploy(neoClient, owner)
+		h, err := ensureNeoContract(neoClient, owner)
 		if err != nil {
 			return nil, err
 		} else {
 			opts.NEO.Contract = h.StringLE()
 		}
 	}
+	err = checkNeoOwnerContract(neoClient, opts.NEO.Contract)
+	if err != nil {
+		return nil, err
+	}
 	return neoClient, nil
 }
 
-func payoutNEO(addressValues []string, teas []*big.Int) (string, error) {
-	var payoutNeoHash, err = util.Uint160DecodeStringLE(opts.NEO.Contract)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return "", err
+const (
+	NeoErrInvalidAddress  = "invalid_address"
+	NeoErrInsufficientGas = "insufficient_gas"
+	NeoErrNetworkMismatch = "network_mismatch"
+	NeoErrRPCTimeout      = "rpc_timeout"
+	NeoErrRPC             = "rpc"
+	NeoErrConfig          = "config"
+	NeoErrChunkTooLarge   = "chunk_too_large"
+	NeoErrDryRunFault     = "dry_run_fault"
+)
+
+// NeoError is returned by the NEO transaction pipeline, Code tells the caller what went wrong
+type NeoError struct {
+	Code string
+	Err  error
+}
+
+func (e *NeoError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Code, e.Err)
+}
+
+func (e *NeoError) Unwrap() error {
+	return e.Err
+}
+
+// neoRPCError classifies an error returned by the NEO RPC node
+func neoRPCError(msg string, err error) error {
+	code := NeoErrRPC
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		code = NeoErrRPCTimeout
+	case strings.Contains(err.Error(), "insufficient funds") || strings.Contains(err.Error(), "GAS limit exceeded"):
+		code = NeoErrInsufficientGas
+	case strings.Contains(err.Error(), "bad vm state"):
+		code = NeoErrDryRunFault
 	}
-	contractOwnerPrivateKey, err := keys.NewPrivateKeyFromWIF(opts.NEO.PrivateKey)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return "", err
+	return &NeoError{Code: code, Err: fmt.Errorf("%v: %w", msg, err)}
+}
+
+// isNeoConfigErr is true for a NeoError with the code NeoErrConfig
+func isNeoConfigErr(err error) bool {
+	var neoErr *NeoError
+	return errors.As(err, &neoErr) && neoErr.Code == NeoErrConfig
+}
+
+// neoErrStatus maps the errors of the NEO transaction pipeline to the HTTP status code
+func neoErrStatus(err error) int {
+	var neoErr *NeoError
+	if !errors.As(err, &neoErr) {
+		return http.StatusInternalServerError
+	}
+	switch neoErr.Code {
+	case NeoErrInvalidAddress:
+		return http.StatusBadRequest
+	case NeoErrInsufficientGas, NeoErrChunkTooLarge, NeoErrDryRunFault:
+		return http.StatusConflict
+	case NeoErrRPCTimeout:
+		return http.StatusGatewayTimeout
+	case NeoErrNetworkMismatch, NeoErrRPC:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
 	}
-	owner := wallet.NewAccountFromPrivateKey(contractOwnerPrivateKey)
+}
 
-	h := CreateBatchPayoutTx(neoClient, payoutNeoHash, owner, addressValues, teas)
-	return h, nil
+// parseNeoAddresses converts NEO3 addresses to script hashes, any invalid address fails all of them
+func parseNeoAddresses(addressValues []string) ([]util.Uint160, error) {
+	accounts := make([]util.Uint160, len(addressValues))
+	for i, v := range addressValues {
+		a, err := address.StringToUint160(v)
+		if err != nil {
+			return nil, &NeoError{Code: NeoErrInvalidAddress, Err: fmt.Errorf("address %v at %v: %w", v, i, err)}
+		}
+		accounts[i] = a
+	}
+	return accounts, nil
 }
 
-func CreateBatchPayoutTx(c *neo.Client, payoutNeoHash util.Uint160, acc *wallet.Account, addressValues []string, teas []*big.Int) string {
-	var devP []interface{}
-	for _, v := range addressValues {
-		add, _ := address.StringToUint160(v)
-		devP = append(devP, add)
+// neoOwner returns the hash of the contract and the owner account that signs the transactions
+func neoOwner() (util.Uint160, *wallet.Account, error) {
+	payoutNeoHash, err := util.Uint160DecodeStringLE(opts.NEO.Contract)
+	if err != nil {
+		return util.Uint160{}, nil, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("invalid contract %v: %w", opts.NEO.Contract, err)}
 	}
-	var teaP []interface{}
-	for _, v := range teas {
-		teaP = append(teaP, v)
+	if len(neoOwners) == 0 {
+		return util.Uint160{}, nil, &NeoError{Code: NeoErrConfig, Err: errors.New("NEO owner is not loaded")}
 	}
+	return payoutNeoHash, neoOwners[0], nil
+}
 
+// batchPayoutScript creates the script calling batchPayout(accounts, teas), or batchPayout(payoutMap) if
+// NEO_PAYOUT_MAP is set
+func batchPayoutScript(payoutNeoHash util.Uint160, accounts []util.Uint160, teas []*big.Int) ([]byte, error) {
+	if len(accounts) != len(teas) {
+		return nil, fmt.Errorf("%v accounts but %v teas", len(accounts), len(teas))
+	}
 	w := io.NewBufBinWriter()
-	emit.AppCall(w.BinWriter, payoutNeoHash, "batchPayout", callflag.All, devP, teaP)
-	script := w.Bytes()
-	log.Printf("About to execute job [batchPayout]")
-	sender := acc.PrivateKey().GetScriptHash()
+	if opts.NeoPayoutMap {
+		//PACKMAP pops key and value for every entry, System.Contract.Call expects the arguments packed in an array
+		for i := len(accounts) - 1; i >= 0; i-- {
+			emit.BigInt(w.BinWriter, teas[i])
+			emit.Bytes(w.BinWriter, accounts[i].BytesBE())
+		}
+		emit.Int(w.BinWriter, int64(len(accounts)))
+		emit.Opcodes(w.BinWriter, opcode.PACKMAP)
+		emit.Int(w.BinWriter, 1)
+		emit.Opcodes(w.BinWriter, opcode.PACK)
+		emit.AppCallNoArgs(w.BinWriter, payoutNeoHash, "batchPayout", callflag.All)
+	} else {
+		var devP []interface{}
+		for _, v := range accounts {
+			devP = append(devP, v)
+		}
+		var teaP []interface{}
+		for _, v := range teas {
+			teaP = append(teaP, v)
+		}
+		emit.AppCall(w.BinWriter, payoutNeoHash, "batchPayout", callflag.All, devP, teaP)
+	}
+	if w.Err != nil {
+		return nil, fmt.Errorf("could not create script: %w", w.Err)
+	}
+	return w.Bytes(), nil
+}
+
+// CreateBatchPayoutTx creates the unsigned batchPayout transaction for one chunk. The script is run with
+// InvokeScript first to get the system fee. If the transaction is larger than the maximum size or the fees are
+// above NEO_MAX_GAS_PER_TX, a NeoError with code NeoErrChunkTooLarge is returned, so the chunk can be split.
+func CreateBatchPayoutTx(c *neo.Client, payoutNeoHash util.Uint160, acc *wallet.Account, accounts []util.Uint160, teas []*big.Int) (*transaction.Transaction, error) {
+	script, err := batchPayoutScript(payoutNeoHash, accounts, teas)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("About to execute job [batchPayout] with %v accounts", len(accounts))
 	signer := transaction.Signer{
-		Account: sender,
+		Account: acc.ScriptHash(),
 		Scopes:  transaction.CalledByEntry,
 	}
-	tx, err := c.CreateTxFromScript(script, acc, -1, 0, []neo.SignerAccount{{
-		Signer: signer,
+
+	//dry run for the system fee
+	res, err := c.InvokeScript(script, []transaction.Signer{signer})
+	if err != nil {
+		return nil, neoRPCError("invoke script", err)
+	}
+	if res.State != "HALT" {
+		if strings.Contains(strings.ToLower(res.FaultException), "gas limit") {
+			return nil, &NeoError{Code: NeoErrChunkTooLarge, Err: fmt.Errorf("dry run: %v", res.FaultException)}
+		}
+		return nil, &NeoError{Code: NeoErrDryRunFault, Err: fmt.Errorf("dry run %v: %v", res.State, res.FaultException)}
+	}
+
+	tx, err := c.CreateTxFromScript(script, acc, res.GasConsumed, 0, []neo.SignerAccount{{
+		Signer:  signer,
+		Account: acc,
 	}})
 	if err != nil {
-		log.Fatalf(err.Error())
+		return nil, neoRPCError("create transaction", err)
 	}
-	net, err := c.GetNetwork()
+
+	if size := io.GetVarSize(tx); size > transaction.MaxTransactionSize {
+		return nil, &NeoError{Code: NeoErrChunkTooLarge, Err: fmt.Errorf("transaction size %v > %v", size, transaction.MaxTransactionSize)}
+	}
+	if fee := tx.SystemFee + tx.NetworkFee; opts.NeoMaxGasPerTx > 0 && fee > opts.NeoMaxGasPerTx {
+		return nil, &NeoError{Code: NeoErrChunkTooLarge, Err: fmt.Errorf("fee %v > %v", fee, opts.NeoMaxGasPerTx)}
+	}
+	return tx, nil
+}
+
+// invokeNeoOwner sends a transaction calling the method of the contract, signed by the owner
+func invokeNeoOwner(method string, args ...interface{}) (util.Uint256, error) {
+	payoutNeoHash, acc, err := neoOwner()
 	if err != nil {
-		log.Fatalf(err.Error())
+		return util.Uint256{}, err
+	}
+	w := io.NewBufBinWriter()
+	emit.AppCall(w.BinWriter, payoutNeoHash, method, callflag.All, args...)
+	if w.Err != nil {
+		return util.Uint256{}, fmt.Errorf("could not create script: %w", w.Err)
 	}
-	err = acc.SignTx(net, tx)
+	tx, err := neoClient.CreateTxFromScript(w.Bytes(), acc, -1, 0, []neo.SignerAccount{{
+		Signer: transaction.Signer{
+			Account: acc.ScriptHash(),
+			Scopes:  transaction.CalledByEntry,
+		},
+		Account: acc,
+	}})
+	if err != nil {
+		return util.Uint256{}, neoRPCError("create transaction "+method, err)
+	}
+	return sendNeoTx(neoClient, acc, tx)
+}
+
+// sendNeoTx signs the transaction with the account and sends it
+func sendNeoTx(c *neo.Client, acc *wallet.Account, tx *transaction.Transaction) (util.Uint256, error) {
+	net, err := checkNeoNetwork(c)
 	if err != nil {
-		log.Fatalf(err.Error())
+		return util.Uint256{}, err
+	}
+	err = signNeoTx(net, acc, tx)
+	if err != nil {
+		return util.Uint256{}, fmt.Errorf("sign transaction: %w", err)
 	}
 	hash, err := c.SendRawTransaction(tx)
 	if err != nil {
-		log.Fatalf("send raw transaction err: %v", err)
+		return util.Uint256{}, neoRPCError("send raw transaction", err)
+	}
+	return hash, nil
+}
+
+// checkNeoNetwork returns the network of the node, it has to match NEO_NETWORK if set
+func checkNeoNetwork(c *neo.Client) (netmode.Magic, error) {
+	net, err := c.GetNetwork()
+	if err != nil {
+		return 0, neoRPCError("get network", err)
+	}
+	if opts.NeoNetwork != 0 && uint32(net) != uint32(opts.NeoNetwork) {
+		return 0, &NeoError{Code: NeoErrNetworkMismatch, Err: fmt.Errorf("node is on network %v, expected %v", net, opts.NeoNetwork)}
+	}
+	return net, nil
+}
+
+// signNeoWithdraw creates the signature checked by PayoutNeo.withdraw(account, tea, signature). The contract
+// verifies the secp256r1 signature over sha256(account ‖ tea), where account are the 20 bytes of the script hash
+// and tea is the integer as little-endian two's complement, as the VM converts an integer to a buffer.
+func signNeoWithdraw(account util.Uint160, tea *big.Int, owner *keys.PrivateKey) []byte {
+	return owner.Sign(neoWithdrawMessage(account, tea))
+}
+
+func neoWithdrawMessage(account util.Uint160, tea *big.Int) []byte {
+	return append(account.BytesBE(), bigint.ToBytes(tea)...)
+}
+
+// signNeoAndRecord signs PayoutNeo.withdraw with the ledger and policy checks of signAndRecord. The tea has to be
+// above the tea the contract already payed out to the account, see getTea.
+func signNeoAndRecord(addr string, tea *big.Int, subject string, approved bool) (*NeoSignature, error) {
+	if neoClient == nil {
+		return nil, errors.New("NEO is not enabled")
+	}
+	if tea == nil || tea.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: invalid tea %v", errInvalidRequest, tea)
+	}
+	account, err := address.StringToUint160(addr)
+	if err != nil {
+		return nil, &NeoError{Code: NeoErrInvalidAddress, Err: fmt.Errorf("NEO3 address %v: %w", addr, err)}
+	}
+	owner, err := neoOwnerKey()
+	if err != nil {
+		return nil, err
+	}
+	msg := neoWithdrawMessage(account, tea)
+	sig := &NeoSignature{Address: address.Uint160ToString(account), Account: "0x" + account.StringLE(), Tea: tea}
+	e := &LedgerEntry{
+		Chain:   "neo",
+		Account: sig.Address,
+		Amount:  tea,
+		Hash:    sha256.Sum256(msg),
+		Owner:   address.Uint160ToString(owner.GetScriptHash()),
+		Subject: subject,
+	}
+	sig.Claimable, err = signAccountAndRecord(e, approved, func() (*big.Int, error) {
+		return getTea(account)
+	}, func() error {
+		sig.Signature = owner.Sign(msg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return hash.StringLE()
+	return sig, nil
 }
 
 func readNEFFile(filename string) (*nef.File, []byte, error) {
@@ -123,8 +344,7 @@ func readNEFFile(filename string) (*nef.File, []byte, error) {
 
 	f, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Fatalf(err.Error())
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("can't read NEF file: %w", err)
 	}
 
 	nefFile, err := nef.FileFromBytes(f)
@@ -142,8 +362,7 @@ func readManifest(filename string) (*manifest.Manifest, []byte, error) {
 
 	manifestBytes, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Fatalf(err.Error())
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("can't read manifest file: %w", err)
 	}
 
 	m := new(manifest.Manifest)
@@ -154,14 +373,20 @@ func readManifest(filename string) (*manifest.Manifest, []byte, error) {
 	return m, manifestBytes, nil
 }
 
-func deploy(c *neo.Client, acc *wallet.Account) (util.Uint160, error) {
+func deploy(c *neo.Client, acc *wallet.Account) (util.Uint160, util.Uint256, error) {
 	nativeManagementContractHash, err := c.GetNativeContractHash(nativenames.Management)
 	if err != nil {
-		return util.Uint160{}, err
+		return util.Uint160{}, util.Uint256{}, err
 	}
 	ne, nefB, err := readNEFFile("./PayoutNeo.nef")
+	if err != nil {
+		return util.Uint160{}, util.Uint256{}, err
+	}
 	_, mfB, err := readManifest("./PayoutNeo.manifest.json")
-	sender := acc.PrivateKey().GetScriptHash()
+	if err != nil {
+		return util.Uint160{}, util.Uint256{}, err
+	}
+	sender := acc.ScriptHash()
 	pk := acc.PrivateKey().PublicKey().Bytes()
 	appCallParams := []smartcontract.Parameter{
 		{
@@ -178,7 +403,7 @@ func deploy(c *neo.Client, acc *wallet.Account) (util.Uint160, error) {
 		},
 	}
 
-	contractHash := state.CreateContractHash(sender, ne.Checksum, "PayoutNeo")
+	contractHash := state.CreateContractHash(sender, ne.Checksum, neoContractName)
 	signer := transaction.Signer{
 		Account: sender,
 		Scopes:  transaction.Global,
@@ -187,18 +412,132 @@ func deploy(c *neo.Client, acc *wallet.Account) (util.Uint160, error) {
 		//Scopes:           transaction.CustomContracts,
 		//AllowedContracts: []util.Uint160{contractHash},
 	}
-	resp, _ := c.InvokeFunction(nativeManagementContractHash, "deploy", appCallParams, []transaction.Signer{signer})
-	tx, err := c.CreateTxFromScript(resp.Script, acc, -1, 0, []neo.SignerAccount{{Signer: signer}})
+	resp, err := c.InvokeFunction(nativeManagementContractHash, "deploy", appCallParams, []transaction.Signer{signer})
+	if err != nil {
+		return util.Uint160{}, util.Uint256{}, neoRPCError("invoke deploy", err)
+	}
+	tx, err := c.CreateTxFromScript(resp.Script, acc, -1, 0, []neo.SignerAccount{{Signer: signer, Account: acc}})
 	if err != nil {
-		return util.Uint160{}, err
+		return util.Uint160{}, util.Uint256{}, neoRPCError("create deploy transaction", err)
 	}
-	txHash, err := c.SignAndPushTx(tx, acc, nil)
+	txHash, err := sendNeoTx(c, acc, tx)
 	if err != nil {
-		return util.Uint160{}, err
+		return util.Uint160{}, util.Uint256{}, err
 	}
 	fmt.Println("---------------------------------")
 	fmt.Println("NEO Transaction: " + txHash.StringLE())
 	fmt.Println("NEO smart contract deployed: " + contractHash.StringLE())
 	fmt.Println("---------------------------------")
-	return contractHash, nil
+	return contractHash, txHash, nil
+}
+
+// warpNeoChain moves a local privnet forward with NEO_TIMEWARP_METHOD, a dev RPC extension of the node that
+// produces empty blocks, called with the number of blocks and the seconds between the last and the new blocks.
+// It returns false if the chain was not moved, as no method is set.
+func warpNeoChain(seconds int) (bool, error) {
+	if !debug {
+		return false, nil
+	}
+	if opts.NeoTimewarpMethod == "" {
+		log.Printf("NEO_TIMEWARP_METHOD is not set, the NEO chain is not moved by %vs", seconds)
+		return false, nil
+	}
+	c, err := rpc.DialContext(context.Background(), opts.NEO.Url)
+	if err != nil {
+		return false, neoRPCError("dial", err)
+	}
+	defer c.Close()
+	var result interface{}
+	err = c.CallContext(context.Background(), &result, opts.NeoTimewarpMethod, 1, seconds)
+	if err != nil {
+		return false, neoRPCError(opts.NeoTimewarpMethod, err)
+	}
+	return true, nil
+}
+
+// neoChainTime returns the timestamp of the latest block
+func neoChainTime() (time.Time, error) {
+	count, err := neoClient.GetBlockCount()
+	if err != nil {
+		return time.Time{}, neoRPCError("get block count", err)
+	}
+	h, err := neoClient.GetBlockHash(count - 1)
+	if err != nil {
+		return time.Time{}, neoRPCError("get block hash", err)
+	}
+	header, err := neoClient.GetBlockHeader(h)
+	if err != nil {
+		return time.Time{}, neoRPCError("get block header", err)
+	}
+	return time.UnixMilli(int64(header.Timestamp)).UTC(), nil
+}
+
+type neoChain struct {
+	network netmode.Magic
+}
+
+func newNeoChain(o *Opts) (PayoutChain, error) {
+	var err error
+	neoOwners, err = loadNeoOwner(o)
+	if err != nil {
+		return nil, fmt.Errorf("could not load NEO owner: %w", err)
+	}
+	neoClient, err = neoInit()
+	if err != nil {
+		return nil, err
+	}
+	network, err := checkNeoNetwork(neoClient)
+	if err != nil {
+		return nil, err
+	}
+	go neoJobWorker()
+	if o.NeoIndexer {
+		go neoIndexer()
+	}
+	return &neoChain{network: network}, nil
+}
+
+func (n *neoChain) Name() string {
+	return "neo"
+}
+
+func (n *neoChain) Config() ChainConfig {
+	return ChainConfig{
+		Name:     n.Name(),
+		Contract: opts.NEO.Contract,
+		Network:  fmt.Sprint(uint32(n.network)),
+	}
+}
+
+// SignClaim returns the signature for PayoutNeo.withdraw(account, tea, signature), it may return a PendingApproval
+func (n *neoChain) SignClaim(c Claim, subject string) (interface{}, error) {
+	sig, err := signNeoAndRecord(c.Address, c.Amount, subject, false)
+	if isApprovalRequired(err) {
+		a, err := createAccountApproval(n.Name(), c.Address, c.Amount, subject, err.Error())
+		if err != nil {
+			return nil, fmt.Errorf("could not create approval: %w", err)
+		}
+		return &PendingApproval{ApprovalId: a.Id, Status: a.Status, Rule: RuleApprovalThreshold}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (n *neoChain) PaidOut(c Claim) (*big.Int, error) {
+	account, err := address.StringToUint160(c.Address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: NEO3 address %v: %v", errInvalidRequest, c.Address, err)
+	}
+	return getTea(account)
+}
+
+// BatchPayout queues a payout job, see /admin/payout/neo
+func (n *neoChain) BatchPayout(addresses []string, amounts []*big.Int) (interface{}, error) {
+	return queueNeoJob(addresses, amounts)
+}
+
+func (n *neoChain) Time() (time.Time, error) {
+	return neoChainTime()
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/hash"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/neotest"
+	"github.com/nspcc-dev/neo-go/pkg/neotest/chain"
+	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/manifest"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/nef"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/opcode"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/nspcc-dev/neo-go/pkg/wallet"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeNeoClient returns a client for a node that halts every invocation, enough to build transactions
+func newFakeNeoClient(t *testing.T) *neo.Client {
+	t.Helper()
+	script := []byte{byte(opcode.RET)}
+	ne, err := nef.NewFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := state.Contract{ContractBase: state.ContractBase{
+		Hash:     hash.Hash160(script),
+		NEF:      *ne,
+		Manifest: *manifest.DefaultManifest("PolicyContract"),
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		var res interface{}
+		switch req.Method {
+		case "getblockcount":
+			res = 100
+		case "getnextblockvalidators":
+			res = []result.Validator{}
+		case "getcontractstate":
+			res = policy
+		case "invokescript":
+			res = &result.Invoke{State: "HALT", GasConsumed: 1_000_000, Stack: []stackitem.Item{}}
+		case "invokefunction":
+			//getExecFeeFactor and getFeePerByte
+			res = &result.Invoke{State: "HALT", Stack: []stackitem.Item{stackitem.Make(30)}}
+		default:
+			t.Errorf("unexpected method %v", req.Method)
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.Id, "result": json.RawMessage(b)})
+	}))
+	t.Cleanup(srv.Close)
+	c, err := neo.New(context.Background(), srv.URL, neo.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCreateBatchPayoutTxMultisig(t *testing.T) {
+	opts = &Opts{}
+	t.Cleanup(func() { opts = nil })
+
+	var pubs keys.PublicKeys
+	var key *keys.PrivateKey
+	for i := 0; i < 3; i++ {
+		k, err := keys.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = k
+		pubs = append(pubs, k.PublicKey())
+	}
+	acc := wallet.NewAccountFromPrivateKey(key)
+	if err := acc.ConvertMultisig(2, pubs); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := []util.Uint160{{1}, {2}}
+	teas := []*big.Int{big.NewInt(10), big.NewInt(20)}
+	tx, err := CreateBatchPayoutTx(newFakeNeoClient(t), util.Uint160{0xff}, acc, accounts, teas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Signers) != 1 {
+		t.Fatalf("%v signers, expected only the sender", len(tx.Signers))
+	}
+	if !tx.Sender().Equals(acc.ScriptHash()) {
+		t.Fatalf("sender is %v, expected the multisig %v", tx.Sender().StringLE(), acc.ScriptHash().StringLE())
+	}
+	if tx.Signers[0].Scopes != transaction.CalledByEntry {
+		t.Fatalf("scope is %v, expected CalledByEntry", tx.Signers[0].Scopes)
+	}
+	if tx.SystemFee != 1_000_000 || tx.NetworkFee <= 0 {
+		t.Fatalf("system fee %v, network fee %v", tx.SystemFee, tx.NetworkFee)
+	}
+}
+
+// TestPayoutNeoUpdate deploys PayoutNeo.nef on a single node chain, pays out with a signed withdraw and updates the
+// contract, which keeps the teas
+func TestPayoutNeoUpdate(t *testing.T) {
+	bc, validator := chain.NewSingle(t)
+	e := neotest.NewExecutor(t, bc, validator, validator)
+	owner := e.NewAccount(t, 1000_0000_0000).(neotest.SingleSigner)
+	ownerKey := owner.Account().PrivateKey()
+
+	ne, nefB, err := readNEFFile("./PayoutNeo.nef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, mfB, err := readManifest("./PayoutNeo.manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := state.CreateContractHash(owner.ScriptHash(), ne.Checksum, neoContractName)
+	e.DeployContractBy(t, owner, &neotest.Contract{Hash: h, NEF: ne, Manifest: m}, ownerKey.PublicKey().Bytes())
+	e.ValidatorInvoker(e.NativeHash(t, nativenames.Gas)).Invoke(t, true, "transfer", validator.ScriptHash(), h, 10_0000_0000, nil)
+
+	dev := util.Uint160{0xd1}
+	tea := big.NewInt(100)
+	anyone := e.NewInvoker(h, validator)
+	anyone.Invoke(t, stackitem.Null{}, "withdraw", dev, tea, signNeoWithdraw(dev, tea, ownerKey))
+	e.CheckGASBalance(t, dev, tea)
+	anyone.InvokeFail(t, "These funds have already been withdrawn.", "withdraw", dev, tea, signNeoWithdraw(dev, tea, ownerKey))
+
+	anyone.InvokeFail(t, "No authorization.", "update", nefB, mfB, nil)
+	e.NewInvoker(h, owner).Invoke(t, stackitem.Null{}, "update", nefB, mfB, nil)
+	if cs := bc.GetContractState(h); cs == nil || cs.UpdateCounter != 1 {
+		t.Fatalf("contract is not updated: %+v", cs)
+	}
+	anyone.Invoke(t, tea.Int64(), "getTea", dev)
+}
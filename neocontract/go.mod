@@ -0,0 +1,5 @@
+module github.com/flatfeestack/payout/neocontract
+
+go 1.19
+
+require github.com/nspcc-dev/neo-go/pkg/interop v0.0.0-20220927123257-24c107e3a262
@@ -0,0 +1,167 @@
+// Package neocontract is the PayoutNeo contract. It keeps the storage layout, methods and event of the first
+// PayoutNeo, and adds update, so a new version keeps the teas.
+package neocontract
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neo-go/pkg/interop/convert"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/crypto"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/gas"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/management"
+	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+)
+
+const (
+	// ownerKey holds the public key of the owner
+	ownerKey = "\x01\x02"
+	// teaPrefix followed by the account holds the tea payed out so far
+	teaPrefix = "\x10"
+	// teaEvent is sent when a tea is set without a payment
+	teaEvent = "onTeaUpdateWithoutPayment"
+)
+
+func _deploy(data interface{}, isUpdate bool) {
+	if isUpdate {
+		return
+	}
+	owner := data.(interop.PublicKey)
+	if len(owner) != 33 {
+		panic("Passed public key must match a witness.")
+	}
+	if !runtime.CheckWitness(owner) {
+		panic("Passed public key must match a witness.")
+	}
+	storage.Put(storage.GetContext(), ownerKey, owner)
+}
+
+// OnNEP17Payment accepts GAS to pay out
+func OnNEP17Payment(from interop.Hash160, amount int, data interface{}) {
+}
+
+// GetOwner returns the public key of the owner
+func GetOwner() interop.PublicKey {
+	return storage.Get(storage.GetReadOnlyContext(), ownerKey).(interop.PublicKey)
+}
+
+func checkOwner() {
+	if !runtime.CheckWitness(GetOwner()) {
+		panic("No authorization.")
+	}
+}
+
+// ChangeOwner sets a new owner, both the owner and the new owner have to witness it
+func ChangeOwner(newOwner interop.PublicKey) {
+	checkOwner()
+	if !runtime.CheckWitness(newOwner) {
+		panic("The new owner must witness this change.")
+	}
+	storage.Put(storage.GetContext(), ownerKey, newOwner)
+}
+
+// Update replaces the contract, the storage is kept. Only the owner can update.
+func Update(nef []byte, manifest []byte, data interface{}) {
+	checkOwner()
+	management.UpdateWithData(nef, manifest, data)
+}
+
+// GetTea returns the tea payed out to the account so far
+func GetTea(account interop.Hash160) int {
+	return getTea(storage.GetReadOnlyContext(), account)
+}
+
+func getTea(ctx storage.Context, account interop.Hash160) int {
+	v := storage.Get(ctx, append([]byte(teaPrefix), account...))
+	if v == nil {
+		return 0
+	}
+	return convert.ToInteger(v)
+}
+
+// SetTea sets the tea without a payment, e.g., when it was payed out on another chain
+func SetTea(account interop.Hash160, oldTea int, newTea int) {
+	checkOwner()
+	ctx := storage.GetContext()
+	if getTea(ctx, account) != oldTea {
+		panic("Stored tea is not equal to the provided oldTea.")
+	}
+	if newTea <= oldTea {
+		panic("The provided amount is lower than or equal to the stored tea.")
+	}
+	storage.Put(ctx, append([]byte(teaPrefix), account...), newTea)
+	runtime.Notify(teaEvent, account, oldTea, newTea)
+}
+
+// SetTeas is SetTea for many accounts, an account whose stored tea is not oldTea or not below newTea is skipped
+func SetTeas(accounts []interop.Hash160, oldTeas []int, newTeas []int) {
+	checkOwner()
+	if len(accounts) != len(oldTeas) || len(accounts) != len(newTeas) {
+		panic("Parameters must have same length.")
+	}
+	ctx := storage.GetContext()
+	for i := 0; i < len(accounts); i++ {
+		if getTea(ctx, accounts[i]) != oldTeas[i] || newTeas[i] <= oldTeas[i] {
+			continue
+		}
+		storage.Put(ctx, append([]byte(teaPrefix), accounts[i]...), newTeas[i])
+		runtime.Notify(teaEvent, accounts[i], oldTeas[i], newTeas[i])
+	}
+}
+
+// Withdraw pays out the difference to the stored tea, signature is the signature of the owner over
+// sha256(account ‖ tea)
+func Withdraw(account interop.Hash160, tea int, signature []byte) {
+	msg := append(convert.ToBytes(account), convert.ToBytes(tea)...)
+	if !crypto.VerifyWithECDsa(msg, GetOwner(), interop.Signature(signature), crypto.Secp256r1) {
+		panic("Signature invalid.")
+	}
+	payout(account, tea)
+}
+
+// WithdrawOwner is withdraw(account, tea) called by the owner, without signature
+func WithdrawOwner(account interop.Hash160, tea int) {
+	checkOwner()
+	payout(account, tea)
+}
+
+func payout(account interop.Hash160, tea int) {
+	ctx := storage.GetContext()
+	amount := tea - getTea(ctx, account)
+	if amount <= 0 {
+		panic("These funds have already been withdrawn.")
+	}
+	storage.Put(ctx, append([]byte(teaPrefix), account...), tea)
+	if !gas.Transfer(runtime.GetExecutingScriptHash(), account, amount, nil) {
+		panic("Transfer was not successful.")
+	}
+}
+
+// BatchPayout pays out the difference to the stored tea for every account, accounts without new tea are skipped
+func BatchPayout(accounts []interop.Hash160, teas []int) {
+	checkOwner()
+	if len(accounts) != len(teas) {
+		panic("The parameters must have the same length.")
+	}
+	ctx := storage.GetContext()
+	for i := 0; i < len(accounts); i++ {
+		batchPayout(ctx, accounts[i], teas[i])
+	}
+}
+
+// BatchPayoutMap is batchPayout(payoutMap) with the teas by account
+func BatchPayoutMap(payoutMap map[string]int) {
+	checkOwner()
+	ctx := storage.GetContext()
+	for account, tea := range payoutMap {
+		batchPayout(ctx, interop.Hash160(account), tea)
+	}
+}
+
+func batchPayout(ctx storage.Context, account interop.Hash160, tea int) {
+	amount := tea - getTea(ctx, account)
+	if amount <= 0 {
+		return
+	}
+	storage.Put(ctx, append([]byte(teaPrefix), account...), tea)
+	gas.Transfer(runtime.GetExecutingScriptHash(), account, amount, nil)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/context"
+	"github.com/nspcc-dev/neo-go/pkg/vm"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var bucketNeoCosign = []byte("neo_cosign")
+
+// serializes the signatures added to a transaction and its broadcast
+var neoCosignMu sync.Mutex
+
+// NeoCosign is a payout transaction of a multi-signature owner that waits for the signatures of the other key
+// holders. Context is the ParameterContext as used by neo-go, e.g., with "neo-go wallet sign".
+type NeoCosign struct {
+	TxHash          string                    `json:"txHash"`
+	JobId           uuid.UUID                 `json:"jobId"`
+	Chunk           int                       `json:"chunk"`
+	Status          string                    `json:"status"`
+	Signatures      int                       `json:"signatures"`
+	Required        int                       `json:"required"`
+	ValidUntilBlock uint32                    `json:"validUntilBlock"`
+	Error           string                    `json:"error,omitempty"`
+	Context         *context.ParameterContext `json:"context"`
+	CreatedAt       time.Time                 `json:"createdAt"`
+	UpdatedAt       time.Time                 `json:"updatedAt"`
+}
+
+// createNeoCosign signs the transaction with the local keys and stores it until enough signatures are added
+func createNeoCosign(jobId uuid.UUID, chunk int, tx *transaction.Transaction) (*NeoCosign, error) {
+	net, err := checkNeoNetwork(neoClient)
+	if err != nil {
+		return nil, err
+	}
+	owner := neoOwners[0]
+	m, _, ok := vm.ParseMultiSigContract(owner.Contract.Script)
+	if !ok {
+		return nil, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("owner %v is not a multi-signature account", owner.Address)}
+	}
+	ctx := context.NewParameterContext(context.TransactionType, net, tx)
+	for _, a := range neoOwners {
+		err = ctx.AddSignature(owner.ScriptHash(), owner.Contract, a.PublicKey(), a.SignHashable(net, tx))
+		if err != nil {
+			return nil, fmt.Errorf("add signature of %v: %w", a.Address, err)
+		}
+	}
+	now := timeNow()
+	cs := &NeoCosign{
+		TxHash:          tx.Hash().StringLE(),
+		JobId:           jobId,
+		Chunk:           chunk,
+		Status:          NeoJobSigning,
+		Signatures:      len(neoOwners),
+		Required:        m,
+		ValidUntilBlock: tx.ValidUntilBlock,
+		Context:         ctx,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	return cs, saveNeoCosign(cs)
+}
+
+func neoCosigns(w http.ResponseWriter, _ *http.Request, _ string) {
+	result := []NeoCosign{}
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNeoCosign).ForEach(func(_, v []byte) error {
+			var cs NeoCosign
+			if err := json.Unmarshal(v, &cs); err != nil {
+				return err
+			}
+			result = append(result, cs)
+			return nil
+		})
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read NEO co-sign transactions: %v", err)
+		return
+	}
+	writeJson(w, result)
+}
+
+// neoCosignGet returns the ParameterContext of the transaction, to be signed offline
+func neoCosignGet(w http.ResponseWriter, r *http.Request, _ string) {
+	cs, err := loadNeoCosign(mux.Vars(r)["txHash"])
+	if err != nil {
+		writeErr(w, http.StatusNotFound, "could not read NEO co-sign transaction: %v", err)
+		return
+	}
+	writeJson(w, cs.Context)
+}
+
+// neoCosignPost adds the signatures of a signed ParameterContext. The transaction is sent as soon as it has
+// enough signatures, and the payout job continues.
+func neoCosignPost(w http.ResponseWriter, r *http.Request, email string) {
+	var signed context.ParameterContext
+	err := json.NewDecoder(r.Body).Decode(&signed)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode ParameterContext: %v", err)
+		return
+	}
+
+	if len(neoOwners) == 0 {
+		writeErr(w, http.StatusServiceUnavailable, "NEO owner is not loaded")
+		return
+	}
+
+	neoCosignMu.Lock()
+	defer neoCosignMu.Unlock()
+
+	cs, err := loadNeoCosign(mux.Vars(r)["txHash"])
+	if err != nil {
+		writeErr(w, http.StatusNotFound, "could not read NEO co-sign transaction: %v", err)
+		return
+	}
+	if cs.Status != NeoJobSigning {
+		writeErr(w, http.StatusConflict, "NEO transaction %v is %v", cs.TxHash, cs.Status)
+		return
+	}
+	if signed.Verifiable == nil || !signed.Verifiable.Hash().Equals(cs.Context.Verifiable.Hash()) {
+		writeErr(w, http.StatusBadRequest, "ParameterContext is not for transaction %v", cs.TxHash)
+		return
+	}
+
+	owner := neoOwners[0]
+	item, ok := signed.Items[owner.ScriptHash()]
+	if !ok {
+		writeErr(w, http.StatusBadRequest, "no signatures for %v", owner.Address)
+		return
+	}
+	stored := cs.Context.Items[owner.ScriptHash()]
+	for pubHex, sig := range item.Signatures {
+		pub, err := keys.NewPublicKeyFromString(pubHex)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid public key %v: %v", pubHex, err)
+			return
+		}
+		if stored.GetSignature(pub) != nil {
+			continue
+		}
+		if !pub.VerifyHashable(sig, uint32(cs.Context.Network), cs.Context.Verifiable) {
+			writeErr(w, http.StatusBadRequest, "invalid signature of %v", pubHex)
+			return
+		}
+		err = cs.Context.AddSignature(owner.ScriptHash(), owner.Contract, pub, sig)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "could not add signature of %v: %v", pubHex, err)
+			return
+		}
+		log.Printf("NEO transaction %v signed by %v, added by %v", cs.TxHash, pubHex, email)
+	}
+	cs.Signatures = len(stored.Signatures)
+
+	if cs.Signatures >= cs.Required {
+		err = sendNeoCosign(cs)
+		if err != nil {
+			cs.Status = NeoJobFailed
+			cs.Error = err.Error()
+		} else {
+			cs.Status = NeoJobSubmitted
+		}
+		if updateNeoCosignJob(cs, err) {
+			notifyNeoJobWorker()
+		}
+	}
+	cs.UpdatedAt = timeNow()
+	err = saveNeoCosign(cs)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not store NEO co-sign transaction: %v", err)
+		return
+	}
+	writeJson(w, cs)
+}
+
+func sendNeoCosign(cs *NeoCosign) error {
+	if neoClient == nil {
+		return &NeoError{Code: NeoErrConfig, Err: errors.New("NEO network is not initialized")}
+	}
+	tx, err := cs.Context.GetCompleteTransaction()
+	if err != nil {
+		return fmt.Errorf("complete transaction: %w", err)
+	}
+	_, err = neoClient.SendRawTransaction(tx)
+	if err != nil {
+		return neoRPCError("send raw transaction", err)
+	}
+	return nil
+}
+
+// updateNeoCosignJob marks the chunk of the transaction as sent and returns true if the job continues, or stops the
+// job and returns false
+func updateNeoCosignJob(cs *NeoCosign, sendErr error) bool {
+	job, err := loadNeoJob(cs.JobId)
+	if err != nil || cs.Chunk >= len(job.Chunks) {
+		log.Errorf("could not read NEO payout job %v of transaction %v: %v", cs.JobId, cs.TxHash, err)
+		return false
+	}
+	chunk := job.Chunks[cs.Chunk]
+	if sendErr != nil {
+		chunk.Status = NeoJobFailed
+		chunk.Error = sendErr.Error()
+		var neoErr *NeoError
+		if errors.As(sendErr, &neoErr) {
+			chunk.ErrorCode = neoErr.Code
+		}
+		finishNeoJob(job, chunk)
+		return false
+	}
+	chunk.Status = NeoJobSubmitted
+	updateNeoJob(job)
+	return true
+}
+
+func saveNeoCosign(cs *NeoCosign) error {
+	j, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNeoCosign).Put([]byte(cs.TxHash), j)
+	})
+}
+
+func loadNeoCosign(txHash string) (*NeoCosign, error) {
+	var cs *NeoCosign
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketNeoCosign).Get([]byte(txHash))
+		if v == nil {
+			return fmt.Errorf("transaction %v not found", txHash)
+		}
+		cs = &NeoCosign{}
+		return json.Unmarshal(v, cs)
+	})
+	return cs, err
+}
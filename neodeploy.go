@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+	"github.com/nspcc-dev/neo-go/pkg/wallet"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const neoContractName = "PayoutNeo"
+
+// NeoState is stored in NEO_STATE_FILE after the contract was deployed or updated
+type NeoState struct {
+	Contract  string    `json:"contract"`
+	Sender    string    `json:"sender"`
+	Checksum  uint32    `json:"checksum"`
+	TxHash    string    `json:"txHash"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ensureNeoContract reuses the contract of the sender with the name PayoutNeo if it exists. If the checksum of
+// PayoutNeo.nef changed, the contract is updated, which keeps its storage. Otherwise, a new contract is deployed.
+// Without a state file, a changed checksum cannot be told apart from no contract, so a new contract is only deployed
+// if NEO_CONTRACT is not set to another one.
+func ensureNeoContract(c *neo.Client, acc *wallet.Account) (util.Uint160, error) {
+	if opts.NeoStateFile == "" {
+		return util.Uint160{}, &NeoError{Code: NeoErrConfig, Err: errors.New("NEO_STATE_FILE is required to deploy the NEO contract")}
+	}
+	//the contract stores the public key of the owner
+	if _, err := neoOwnerKey(); err != nil {
+		return util.Uint160{}, err
+	}
+	ne, nefB, err := readNEFFile("./PayoutNeo.nef")
+	if err != nil {
+		return util.Uint160{}, err
+	}
+	_, mfB, err := readManifest("./PayoutNeo.manifest.json")
+	if err != nil {
+		return util.Uint160{}, err
+	}
+	sender := acc.ScriptHash()
+
+	st, err := loadNeoState()
+	if err != nil {
+		return util.Uint160{}, err
+	}
+	//the hash only depends on the checksum of the first deployment, after an update it is taken from the state
+	h := state.CreateContractHash(sender, ne.Checksum, neoContractName)
+	if st != nil && st.Sender == address.Uint160ToString(sender) {
+		h, err = util.Uint160DecodeStringLE(st.Contract)
+		if err != nil {
+			return util.Uint160{}, fmt.Errorf("invalid contract %v in %v: %w", st.Contract, opts.NeoStateFile, err)
+		}
+	}
+
+	cs, err := c.GetContractStateByHash(h)
+	if err != nil && !strings.Contains(err.Error(), "Unknown contract") {
+		return util.Uint160{}, neoRPCError("get contract state", err)
+	}
+	if err != nil {
+		if st == nil && opts.NEO.Contract != "" && opts.NEO.Contract != h.StringLE() {
+			return util.Uint160{}, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("NEO contract %v not found and there is no %v, "+
+				"NEO_CONTRACT %v was deployed from another PayoutNeo.nef. Restore %v to update it, or unset NEO_CONTRACT to deploy a new contract",
+				h.StringLE(), opts.NeoStateFile, opts.NEO.Contract, opts.NeoStateFile)}
+		}
+		log.Printf("NEO contract %v not found, deploying", h.StringLE())
+		h, txHash, err := deploy(c, acc)
+		if err != nil {
+			return util.Uint160{}, err
+		}
+		return h, saveNeoState(h, sender, ne.Checksum, txHash)
+	}
+	if cs.Manifest.Name != neoContractName {
+		return util.Uint160{}, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("contract %v is %v, not %v", h.StringLE(), cs.Manifest.Name, neoContractName)}
+	}
+	if cs.NEF.Checksum == ne.Checksum {
+		log.Printf("NEO contract %v is up to date, reusing it", h.StringLE())
+		if st == nil || st.Contract != h.StringLE() {
+			return h, saveNeoState(h, sender, ne.Checksum, util.Uint256{})
+		}
+		return h, nil
+	}
+
+	log.Printf("NEO contract %v has checksum %v, updating to %v", h.StringLE(), cs.NEF.Checksum, ne.Checksum)
+	txHash, err := updateNeoContract(c, acc, cs, nefB, mfB)
+	if err != nil {
+		return util.Uint160{}, err
+	}
+	return h, saveNeoState(h, sender, ne.Checksum, txHash)
+}
+
+// updateNeoContract calls update(nef, manifest, data) of the contract, which has to call ContractManagement.update,
+// as only the contract itself can update it.
+func updateNeoContract(c *neo.Client, acc *wallet.Account, cs *state.Contract, nefB []byte, mfB []byte) (util.Uint256, error) {
+	if cs.Manifest.ABI.GetMethod("update", 3) == nil {
+		return util.Uint256{}, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("contract %v has no update(nef, manifest, data) method, "+
+			"remove %v to deploy a new contract", cs.Hash.StringLE(), opts.NeoStateFile)}
+	}
+	signer := transaction.Signer{
+		Account: acc.ScriptHash(),
+		Scopes:  transaction.CalledByEntry,
+	}
+	resp, err := c.InvokeFunction(cs.Hash, "update", []smartcontract.Parameter{
+		{Type: smartcontract.ByteArrayType, Value: nefB},
+		{Type: smartcontract.ByteArrayType, Value: mfB},
+		{Type: smartcontract.AnyType},
+	}, []transaction.Signer{signer})
+	if err != nil {
+		return util.Uint256{}, neoRPCError("invoke update", err)
+	}
+	if resp.State != vmstate.Halt.String() {
+		return util.Uint256{}, &NeoError{Code: NeoErrDryRunFault, Err: fmt.Errorf("update: %v", resp.FaultException)}
+	}
+	tx, err := c.CreateTxFromScript(resp.Script, acc, -1, 0, []neo.SignerAccount{{Signer: signer, Account: acc}})
+	if err != nil {
+		return util.Uint256{}, neoRPCError("create update transaction", err)
+	}
+	txHash, err := sendNeoTx(c, acc, tx)
+	if err != nil {
+		return util.Uint256{}, err
+	}
+	e, err := waitNeoTx(c, txHash)
+	if err != nil {
+		return util.Uint256{}, err
+	}
+	if e.VMState != vmstate.Halt {
+		return util.Uint256{}, &NeoError{Code: NeoErrRPC, Err: fmt.Errorf("update transaction %v: %v %v", txHash.StringLE(), e.VMState, e.FaultException)}
+	}
+	log.Printf("NEO contract %v updated in transaction %v", cs.Hash.StringLE(), txHash.StringLE())
+	return txHash, nil
+}
+
+// loadNeoState returns nil if there is no state file
+func loadNeoState() (*NeoState, error) {
+	b, err := ioutil.ReadFile(opts.NeoStateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read NEO state file: %w", err)
+	}
+	st := &NeoState{}
+	err = json.Unmarshal(b, st)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse NEO state file: %w", err)
+	}
+	return st, nil
+}
+
+func saveNeoState(h util.Uint160, sender util.Uint160, checksum uint32, txHash util.Uint256) error {
+	st := NeoState{
+		Contract:  h.StringLE(),
+		Sender:    address.Uint160ToString(sender),
+		Checksum:  checksum,
+		UpdatedAt: timeNow(),
+	}
+	if !txHash.Equals(util.Uint256{}) {
+		st.TxHash = txHash.StringLE()
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	//write to a temporary file first, so a crash does not leave a broken state file
+	tmp := opts.NeoStateFile + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return fmt.Errorf("can't write NEO state file: %w", err)
+	}
+	return os.Rename(tmp, opts.NeoStateFile)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const neoIndexerPollInterval = 15 * time.Second
+
+var bucketNeoEvents = []byte("neo_events")
+var bucketNeoEventsAccount = []byte("neo_events_account")
+var bucketNeoIndexer = []byte("neo_indexer")
+var keyNeoIndexerHeight = []byte("height")
+
+// neoTransferTokens are the NEP-17 contracts whose transfers are indexed, any contract can emit a Transfer event
+var neoTransferTokens = map[util.Uint160]bool{}
+
+// NeoEvent is a tea update of the contract or a NEP-17 transfer from or to the contract
+type NeoEvent struct {
+	Block    uint32    `json:"block"`
+	Time     time.Time `json:"time"`
+	TxHash   string    `json:"txHash"`
+	Contract string    `json:"contract"`
+	Name     string    `json:"name"`
+	Account  string    `json:"account,omitempty"`
+	OldTea   *big.Int  `json:"oldTea,omitempty"`
+	NewTea   *big.Int  `json:"newTea,omitempty"`
+	From     string    `json:"from,omitempty"`
+	To       string    `json:"to,omitempty"`
+	Amount   *big.Int  `json:"amount,omitempty"`
+}
+
+// neoIndexer stores the events of the NEO contract. New blocks are received with the WebSocket client if
+// NEO_WS_URL is set, otherwise, or if the WebSocket fails, the blocks are polled.
+func neoIndexer() {
+	contract, err := util.Uint160DecodeStringLE(opts.NEO.Contract)
+	if err != nil {
+		log.Errorf("NEO indexer: invalid contract %v: %v", opts.NEO.Contract, err)
+		return
+	}
+	neoTransferTokens, err = parseNeoTokens(opts.NeoIndexerTokens)
+	if err != nil {
+		log.Errorf("NEO indexer: %v", err)
+		return
+	}
+
+	for {
+		if opts.NeoWsUrl != "" {
+			err = neoIndexerWs(contract)
+			log.Errorf("NEO indexer: WebSocket failed, polling: %v", err)
+		}
+		err = neoIndexBlocks(contract)
+		if err != nil {
+			log.Errorf("NEO indexer: %v", err)
+		}
+		time.Sleep(neoIndexerPollInterval)
+	}
+}
+
+// neoIndexerWs indexes the blocks up to the latest one every time a new block is received
+func neoIndexerWs(contract util.Uint160) error {
+	ws, err := neo.NewWS(context.Background(), opts.NeoWsUrl, neo.Options{})
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	err = ws.Init()
+	if err != nil {
+		return err
+	}
+	blocks := make(chan *block.Block, 10)
+	_, err = ws.ReceiveBlocks(nil, blocks)
+	if err != nil {
+		return err
+	}
+	log.Printf("NEO indexer: receiving blocks from %v", opts.NeoWsUrl)
+	for range blocks {
+		err = neoIndexBlocks(contract)
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("block subscription closed")
+}
+
+// neoIndexBlocks indexes all blocks from the checkpoint to the current height
+func neoIndexBlocks(contract util.Uint160) error {
+	count, err := neoClient.GetBlockCount()
+	if err != nil {
+		return neoRPCError("get block count", err)
+	}
+	next, err := neoIndexerHeight()
+	if err != nil {
+		return err
+	}
+	for ; next < count; next++ {
+		b, err := neoClient.GetBlockByIndex(next)
+		if err != nil {
+			return neoRPCError(fmt.Sprintf("get block %v", next), err)
+		}
+		var events []NeoEvent
+		for _, tx := range b.Transactions {
+			appLog, err := neoClient.GetApplicationLog(tx.Hash(), nil)
+			if err != nil {
+				return neoRPCError(fmt.Sprintf("get application log %v", tx.Hash().StringLE()), err)
+			}
+			for _, e := range appLog.Executions {
+				for _, n := range e.Events {
+					if ev := neoEvent(contract, b, tx.Hash(), n); ev != nil {
+						events = append(events, *ev)
+					}
+				}
+			}
+		}
+		err = storeNeoEvents(next, events)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			log.Printf("NEO indexer: %v events in block %v", len(events), next)
+		}
+	}
+	return nil
+}
+
+// neoEvent converts a notification to an event, nil if it is not relevant for the contract
+func neoEvent(contract util.Uint160, b *block.Block, txHash util.Uint256, n state.NotificationEvent) *NeoEvent {
+	if n.Item == nil {
+		return nil
+	}
+	items, ok := n.Item.Value().([]stackitem.Item)
+	if !ok || len(items) != 3 {
+		return nil
+	}
+	ev := &NeoEvent{
+		Block:    b.Index,
+		Time:     time.UnixMilli(int64(b.Timestamp)).UTC(),
+		TxHash:   txHash.StringLE(),
+		Contract: n.ScriptHash.StringLE(),
+		Name:     n.Name,
+	}
+	switch {
+	case n.ScriptHash == contract && n.Name == neoTeaUpdateEvent:
+		account, ok := neoEventAccount(items[0])
+		if !ok {
+			return nil
+		}
+		ev.Account = account
+		ev.OldTea, _ = items[1].TryInteger()
+		ev.NewTea, _ = items[2].TryInteger()
+		return ev
+	case n.Name == "Transfer" && neoTransferTokens[n.ScriptHash]:
+		from, _ := neoEventAccount(items[0])
+		to, _ := neoEventAccount(items[1])
+		contractAddress := address.Uint160ToString(contract)
+		if from != contractAddress && to != contractAddress {
+			return nil
+		}
+		ev.From = from
+		ev.To = to
+		ev.Amount, _ = items[2].TryInteger()
+		return ev
+	}
+	return nil
+}
+
+// parseNeoTokens returns GAS and the comma separated contract hashes
+func parseNeoTokens(tokens string) (map[util.Uint160]bool, error) {
+	m := map[util.Uint160]bool{state.CreateNativeContractHash(nativenames.Gas): true}
+	for _, t := range strings.Split(tokens, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		h, err := util.Uint160DecodeStringLE(strings.TrimPrefix(t, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid token contract %v: %w", t, err)
+		}
+		m[h] = true
+	}
+	return m, nil
+}
+
+func neoEventAccount(item stackitem.Item) (string, bool) {
+	b, err := item.TryBytes()
+	if err != nil {
+		return "", false
+	}
+	u, err := util.Uint160DecodeBytesBE(b)
+	if err != nil {
+		return "", false
+	}
+	return address.Uint160ToString(u), true
+}
+
+func neoIndexerHeight() (uint32, error) {
+	height := uint32(opts.NeoIndexerStart)
+	err := db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketNeoIndexer).Get(keyNeoIndexerHeight); v != nil {
+			height = binary.BigEndian.Uint32(v)
+		}
+		return nil
+	})
+	return height, err
+}
+
+// storeNeoEvents stores the events of the block and moves the checkpoint to the next block
+func storeNeoEvents(blockIndex uint32, events []NeoEvent) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketNeoEvents)
+		ba := tx.Bucket(bucketNeoEventsAccount)
+		for i, e := range events {
+			key := neoEventKey(blockIndex, e.TxHash, i)
+			j, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err = b.Put(key, j); err != nil {
+				return err
+			}
+			for _, a := range []string{e.Account, e.From, e.To} {
+				if a == "" {
+					continue
+				}
+				if err = ba.Put(append([]byte(a+"/"), key...), nil); err != nil {
+					return err
+				}
+			}
+		}
+		h := make([]byte, 4)
+		binary.BigEndian.PutUint32(h, blockIndex+1)
+		return tx.Bucket(bucketNeoIndexer).Put(keyNeoIndexerHeight, h)
+	})
+}
+
+func neoEventKey(blockIndex uint32, txHash string, i int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint32(key[:4], blockIndex)
+	binary.BigEndian.PutUint32(key[4:], uint32(i))
+	return append(key, []byte(txHash)...)
+}
+
+// neoEvents returns the events of an account, ordered by block
+func neoEvents(w http.ResponseWriter, r *http.Request, _ string) {
+	a := mux.Vars(r)["address"]
+	if _, err := address.StringToUint160(a); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid NEO3 address %v: %v", a, err)
+		return
+	}
+	events := []NeoEvent{}
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketNeoEvents)
+		prefix := []byte(a + "/")
+		c := tx.Bucket(bucketNeoEventsAccount).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && len(k) > len(prefix) && string(k[:len(prefix)]) == string(prefix); k, _ = c.Next() {
+			var e NeoEvent
+			if err := json.Unmarshal(b.Get(k[len(prefix):]), &e); err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not read NEO events: %v", err)
+		return
+	}
+	writeJson(w, events)
+}
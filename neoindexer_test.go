@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"math/big"
+	"testing"
+)
+
+func TestNeoEventTransfer(t *testing.T) {
+	contract := util.Uint160{1}
+	dev := util.Uint160{2}
+	token := util.Uint160{3}
+	foreign := util.Uint160{4}
+	var err error
+	neoTransferTokens, err = parseNeoTokens("0x" + token.StringLE())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &block.Block{}
+	transfer := func(scriptHash util.Uint160) state.NotificationEvent {
+		return state.NotificationEvent{
+			ScriptHash: scriptHash,
+			Name:       "Transfer",
+			Item: stackitem.NewArray([]stackitem.Item{
+				stackitem.NewByteArray(contract.BytesBE()),
+				stackitem.NewByteArray(dev.BytesBE()),
+				stackitem.NewBigInteger(big.NewInt(100)),
+			}),
+		}
+	}
+
+	gas := state.CreateNativeContractHash(nativenames.Gas)
+	ev := neoEvent(contract, b, util.Uint256{}, transfer(gas))
+	if ev == nil || ev.From != address.Uint160ToString(contract) || ev.To != address.Uint160ToString(dev) || ev.Amount.Int64() != 100 {
+		t.Fatalf("GAS transfer not indexed: %+v", ev)
+	}
+	if ev = neoEvent(contract, b, util.Uint256{}, transfer(token)); ev == nil {
+		t.Fatal("transfer of a configured token not indexed")
+	}
+	//any contract can emit a Transfer event with the payout contract as sender
+	if ev = neoEvent(contract, b, util.Uint256{}, transfer(foreign)); ev != nil {
+		t.Fatalf("transfer of a foreign contract indexed: %+v", ev)
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	NeoJobQueued    = "queued"
+	NeoJobRunning   = "running"
+	NeoJobSigning   = "signing"
+	NeoJobSubmitted = "submitted"
+	NeoJobHalt      = "halt"
+	NeoJobFault     = "fault"
+	NeoJobFailed    = "failed"
+
+	neoJobPollInterval = 5 * time.Second
+	neoJobPollTimeout  = 10 * time.Minute
+)
+
+var bucketNeoJobs = []byte("neo_jobs")
+
+// neoJobWake wakes up the worker to look for queued jobs, a pending wake up is enough for any number of new jobs
+var neoJobWake = make(chan struct{}, 1)
+
+// NeoPayoutJob is a batchPayout on the NEO contract. The recipients are split into chunks that fit into one
+// transaction, the chunks are submitted in order and each one is polled until its transaction is executed.
+type NeoPayoutJob struct {
+	Id        uuid.UUID         `json:"id"`
+	Addresses []string          `json:"addresses"`
+	Teas      []*big.Int        `json:"teas"`
+	Status    string            `json:"status"`
+	Chunks    []*NeoPayoutChunk `json:"chunks"`
+	Error     string            `json:"error,omitempty"`
+	ErrorCode string            `json:"errorCode,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// NeoPayoutChunk is one transaction of a job, it pays out the addresses From (inclusive) to To (exclusive)
+type NeoPayoutChunk struct {
+	From           int    `json:"from"`
+	To             int    `json:"to"`
+	Status         string `json:"status"`
+	TxHash         string `json:"txHash,omitempty"`
+	SystemFee      int64  `json:"systemFee,omitempty"`
+	NetworkFee     int64  `json:"networkFee,omitempty"`
+	VMState        string `json:"vmState,omitempty"`
+	FaultException string `json:"faultException,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ErrorCode      string `json:"errorCode,omitempty"`
+}
+
+type NeoBatchPayoutRequest struct {
+	Addresses []string   `json:"addresses"`
+	Teas      []*big.Int `json:"teas"`
+}
+
+func neoPayout(w http.ResponseWriter, r *http.Request, _ string) {
+	if neoClient == nil {
+		writeErr(w, http.StatusServiceUnavailable, "NEO network is not initialized")
+		return
+	}
+	var data NeoBatchPayoutRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode NEO payout request: %v", err)
+		return
+	}
+	job, err := queueNeoJob(data.Addresses, data.Teas)
+	if err != nil {
+		writeErr(w, chainErrStatus(err), "%v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJson(w, job)
+}
+
+// queueNeoJob stores a new job and queues it for the worker
+func queueNeoJob(addresses []string, teas []*big.Int) (*NeoPayoutJob, error) {
+	err := checkBatch(addresses, teas)
+	if err != nil {
+		return nil, err
+	}
+	//reject invalid addresses before anything is queued
+	_, err = parseNeoAddresses(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeNow()
+	job := &NeoPayoutJob{
+		Id:        uuid.New(),
+		Addresses: addresses,
+		Teas:      teas,
+		Status:    NeoJobQueued,
+		Chunks:    []*NeoPayoutChunk{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = saveNeoJob(job)
+	if err != nil {
+		return nil, fmt.Errorf("could not store NEO payout job: %w", err)
+	}
+	notifyNeoJobWorker()
+	return job, nil
+}
+
+func neoPayoutJob(w http.ResponseWriter, r *http.Request, _ string) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid job id: %v", err)
+		return
+	}
+	job, err := loadNeoJob(id)
+	if err != nil {
+		writeErr(w, http.StatusNotFound, "could not read NEO payout job: %v", err)
+		return
+	}
+	writeJson(w, job)
+}
+
+// neoJobWorker runs the NEO payout jobs one after the other. The jobs are read from the database, so the jobs of a
+// previous run are resumed and a wake up is never lost.
+func neoJobWorker() {
+	for {
+		for _, id := range unfinishedNeoJobs() {
+			runNeoJob(id)
+		}
+		<-neoJobWake
+	}
+}
+
+// notifyNeoJobWorker wakes up the worker without waiting for it
+func notifyNeoJobWorker() {
+	select {
+	case neoJobWake <- struct{}{}:
+	default:
+	}
+}
+
+// unfinishedNeoJobs returns the ids of the queued and running jobs, the oldest first
+func unfinishedNeoJobs() []uuid.UUID {
+	var unfinished []*NeoPayoutJob
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNeoJobs).ForEach(func(k, v []byte) error {
+			job := &NeoPayoutJob{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return err
+			}
+			if job.Status == NeoJobQueued || job.Status == NeoJobRunning {
+				unfinished = append(unfinished, job)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Errorf("could not read unfinished NEO payout jobs: %v", err)
+	}
+	sort.Slice(unfinished, func(i, j int) bool {
+		return unfinished[i].CreatedAt.Before(unfinished[j].CreatedAt)
+	})
+	ids := make([]uuid.UUID, len(unfinished))
+	for i, job := range unfinished {
+		ids[i] = job.Id
+	}
+	return ids
+}
+
+func runNeoJob(id uuid.UUID) {
+	job, err := loadNeoJob(id)
+	if err != nil {
+		log.Errorf("could not read NEO payout job %v: %v", id, err)
+		return
+	}
+	if job.Status == NeoJobQueued {
+		job.Status = NeoJobRunning
+		updateNeoJob(job)
+	}
+	if job.Status != NeoJobRunning {
+		return
+	}
+
+	payoutNeoHash, acc, err := neoOwner()
+	if err != nil {
+		failNeoJob(job, err)
+		return
+	}
+	accounts, err := parseNeoAddresses(job.Addresses)
+	if err != nil {
+		failNeoJob(job, err)
+		return
+	}
+
+	//resume after the last chunk of a previous run
+	next := 0
+	if n := len(job.Chunks); n > 0 {
+		last := job.Chunks[n-1]
+		if last.Status == NeoJobSigning {
+			//continued when the transaction is co-signed
+			return
+		}
+		if last.Status == NeoJobSubmitted {
+			pollNeoChunk(job, last)
+		}
+		if last.Status != NeoJobHalt {
+			finishNeoJob(job, last)
+			return
+		}
+		next = last.To
+	}
+
+	size := opts.NeoChunkSize
+	for next < len(accounts) {
+		to := next + size
+		if to > len(accounts) {
+			to = len(accounts)
+		}
+		tx, err := CreateBatchPayoutTx(neoClient, payoutNeoHash, acc, accounts[next:to], job.Teas[next:to])
+		var neoErr *NeoError
+		if errors.As(err, &neoErr) && neoErr.Code == NeoErrChunkTooLarge && to-next > 1 {
+			size = (to - next) / 2
+			log.Printf("NEO payout job %v: chunk of %v too large, trying %v: %v", job.Id, to-next, size, err)
+			continue
+		}
+
+		chunk := &NeoPayoutChunk{From: next, To: to, Status: NeoJobSubmitted}
+		job.Chunks = append(job.Chunks, chunk)
+		if err == nil {
+			chunk.SystemFee = tx.SystemFee
+			chunk.NetworkFee = tx.NetworkFee
+			if neoCosignRequired() {
+				chunk.TxHash = tx.Hash().StringLE()
+				_, err = createNeoCosign(job.Id, len(job.Chunks)-1, tx)
+				if err == nil {
+					chunk.Status = NeoJobSigning
+					updateNeoJob(job)
+					log.Printf("NEO payout job %v, chunk %v-%v: waiting for co-signers of %v", job.Id, next, to, chunk.TxHash)
+					return
+				}
+			} else {
+				var h util.Uint256
+				h, err = sendNeoTx(neoClient, acc, tx)
+				chunk.TxHash = h.StringLE()
+			}
+		}
+		if err != nil {
+			chunk.Status = NeoJobFailed
+			chunk.Error = err.Error()
+			if errors.As(err, &neoErr) {
+				chunk.ErrorCode = neoErr.Code
+			}
+			finishNeoJob(job, chunk)
+			return
+		}
+		updateNeoJob(job)
+
+		pollNeoChunk(job, chunk)
+		if chunk.Status != NeoJobHalt {
+			finishNeoJob(job, chunk)
+			return
+		}
+		next = to
+	}
+
+	job.Status = NeoJobHalt
+	updateNeoJob(job)
+	log.Printf("NEO payout job %v: all %v chunks executed", job.Id, len(job.Chunks))
+}
+
+// finishNeoJob stops the job at a chunk that did not halt, the following chunks are not submitted
+func finishNeoJob(job *NeoPayoutJob, chunk *NeoPayoutChunk) {
+	job.Status = chunk.Status
+	job.Error = chunk.Error
+	job.ErrorCode = chunk.ErrorCode
+	updateNeoJob(job)
+	log.Printf("NEO payout job %v stopped at chunk %v-%v: %v", job.Id, chunk.From, chunk.To, chunk.Status)
+}
+
+func failNeoJob(job *NeoPayoutJob, err error) {
+	job.Status = NeoJobFailed
+	job.Error = err.Error()
+	var neoErr *NeoError
+	if errors.As(err, &neoErr) {
+		job.ErrorCode = neoErr.Code
+	}
+	updateNeoJob(job)
+}
+
+// pollNeoChunk waits for the application log of the transaction and stores the VM state
+func pollNeoChunk(job *NeoPayoutJob, chunk *NeoPayoutChunk) {
+	h, err := util.Uint256DecodeStringLE(chunk.TxHash)
+	if err != nil {
+		chunk.Status = NeoJobFailed
+		chunk.Error = fmt.Sprintf("invalid transaction hash %v: %v", chunk.TxHash, err)
+		updateNeoJob(job)
+		return
+	}
+
+	e, err := waitNeoTx(neoClient, h)
+	if err != nil {
+		chunk.Status = NeoJobFailed
+		chunk.Error = err.Error()
+		updateNeoJob(job)
+		return
+	}
+	chunk.VMState = e.VMState.String()
+	if e.VMState.HasFlag(vmstate.Halt) {
+		chunk.Status = NeoJobHalt
+	} else {
+		chunk.Status = NeoJobFault
+		chunk.FaultException = e.FaultException
+	}
+	updateNeoJob(job)
+	log.Printf("NEO payout job %v, chunk %v-%v: %v", job.Id, chunk.From, chunk.To, chunk.VMState)
+}
+
+// waitNeoTx polls the application log until the transaction is executed
+func waitNeoTx(c *neo.Client, h util.Uint256) (*state.Execution, error) {
+	deadline := time.Now().Add(neoJobPollTimeout)
+	for time.Now().Before(deadline) {
+		appLog, err := c.GetApplicationLog(h, nil)
+		if err != nil || len(appLog.Executions) == 0 {
+			//not yet in a block
+			time.Sleep(neoJobPollInterval)
+			continue
+		}
+		return &appLog.Executions[0], nil
+	}
+	return nil, &NeoError{Code: NeoErrRPCTimeout, Err: fmt.Errorf("transaction %v not executed within %v", h.StringLE(), neoJobPollTimeout)}
+}
+
+func updateNeoJob(job *NeoPayoutJob) {
+	job.UpdatedAt = timeNow()
+	err := saveNeoJob(job)
+	if err != nil {
+		log.Errorf("could not store NEO payout job %v: %v", job.Id, err)
+	}
+}
+
+func saveNeoJob(job *NeoPayoutJob) error {
+	j, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNeoJobs).Put(job.Id[:], j)
+	})
+}
+
+func loadNeoJob(id uuid.UUID) (*NeoPayoutJob, error) {
+	job := &NeoPayoutJob{}
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketNeoJobs).Get(id[:])
+		if v == nil {
+			return fmt.Errorf("job %v not found", id)
+		}
+		return json.Unmarshal(v, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
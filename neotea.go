@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+	"math/big"
+	"net/http"
+)
+
+const neoTeaUpdateEvent = "onTeaUpdateWithoutPayment"
+
+type NeoTea struct {
+	Address string   `json:"address"`
+	Tea     *big.Int `json:"tea"`
+}
+
+// NeoTeaUpdate sets the tea of the address to NewTea, if the stored tea is OldTea
+type NeoTeaUpdate struct {
+	Address string   `json:"address"`
+	OldTea  *big.Int `json:"oldTea"`
+	NewTea  *big.Int `json:"newTea"`
+}
+
+// NeoTeaUpdateResult reports the transaction and the updates that failed the compare. StoredTea is the tea read
+// after the transaction.
+type NeoTeaUpdateResult struct {
+	TxHash         string         `json:"txHash"`
+	VMState        string         `json:"vmState"`
+	FaultException string         `json:"faultException,omitempty"`
+	Updated        []NeoTeaUpdate `json:"updated"`
+	Failed         []NeoTeaFailed `json:"failed"`
+}
+
+type NeoTeaFailed struct {
+	NeoTeaUpdate
+	StoredTea *big.Int `json:"storedTea"`
+}
+
+// getTea reads the tea of the account with getTea
+func getTea(account util.Uint160) (*big.Int, error) {
+	payoutNeoHash, err := util.Uint160DecodeStringLE(opts.NEO.Contract)
+	if err != nil {
+		return nil, &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("invalid contract %v: %w", opts.NEO.Contract, err)}
+	}
+	res, err := neoClient.InvokeFunction(payoutNeoHash, "getTea", []smartcontract.Parameter{
+		{Type: smartcontract.Hash160Type, Value: account},
+	}, nil)
+	if err != nil {
+		return nil, neoRPCError("invoke getTea", err)
+	}
+	if res.State != "HALT" || len(res.Stack) == 0 {
+		return nil, &NeoError{Code: NeoErrDryRunFault, Err: fmt.Errorf("getTea %v: %v", res.State, res.FaultException)}
+	}
+	return res.Stack[0].TryInteger()
+}
+
+// setTeas calls setTeas on the contract. The contract skips the accounts where the stored tea is not oldTea or
+// newTea is not above it, and notifies onTeaUpdateWithoutPayment for the updated ones. All accounts without a
+// notification failed the compare.
+func setTeas(updates []NeoTeaUpdate) (*NeoTeaUpdateResult, error) {
+	accounts := make([]util.Uint160, len(updates))
+	accountsP := make([]interface{}, len(updates))
+	oldP := make([]interface{}, len(updates))
+	newP := make([]interface{}, len(updates))
+	for i, u := range updates {
+		a, err := address.StringToUint160(u.Address)
+		if err != nil {
+			return nil, &NeoError{Code: NeoErrInvalidAddress, Err: fmt.Errorf("address %v at %v: %w", u.Address, i, err)}
+		}
+		accounts[i] = a
+		accountsP[i] = a
+		oldP[i] = u.OldTea
+		newP[i] = u.NewTea
+	}
+
+	h, err := invokeNeoOwner("setTeas", accountsP, oldP, newP)
+	if err != nil {
+		return nil, err
+	}
+	e, err := waitNeoTx(neoClient, h)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &NeoTeaUpdateResult{
+		TxHash:         h.StringLE(),
+		VMState:        e.VMState.String(),
+		FaultException: e.FaultException,
+		Updated:        []NeoTeaUpdate{},
+		Failed:         []NeoTeaFailed{},
+	}
+	notified := map[util.Uint160]bool{}
+	if e.VMState.HasFlag(vmstate.Halt) {
+		for _, n := range e.Events {
+			if n.Name != neoTeaUpdateEvent || n.Item == nil {
+				continue
+			}
+			items := n.Item.Value().([]stackitem.Item)
+			if len(items) == 0 {
+				continue
+			}
+			b, err := items[0].TryBytes()
+			if err != nil {
+				continue
+			}
+			a, err := util.Uint160DecodeBytesBE(b)
+			if err != nil {
+				continue
+			}
+			notified[a] = true
+		}
+	}
+	for i, u := range updates {
+		if notified[accounts[i]] {
+			result.Updated = append(result.Updated, u)
+			continue
+		}
+		stored, err := getTea(accounts[i])
+		if err != nil {
+			stored = nil
+		}
+		result.Failed = append(result.Failed, NeoTeaFailed{NeoTeaUpdate: u, StoredTea: stored})
+	}
+	return result, nil
+}
+
+func neoTeaGet(w http.ResponseWriter, r *http.Request, _ string) {
+	if neoClient == nil {
+		writeErr(w, http.StatusServiceUnavailable, "NEO network is not initialized")
+		return
+	}
+	a := mux.Vars(r)["address"]
+	account, err := address.StringToUint160(a)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid NEO3 address %v: %v", a, err)
+		return
+	}
+	tea, err := getTea(account)
+	if err != nil {
+		writeErr(w, neoErrStatus(err), "could not read tea: %v", err)
+		return
+	}
+	writeJson(w, NeoTea{Address: a, Tea: tea})
+}
+
+func neoTeaSet(w http.ResponseWriter, r *http.Request, _ string) {
+	if neoClient == nil {
+		writeErr(w, http.StatusServiceUnavailable, "NEO network is not initialized")
+		return
+	}
+	var data []NeoTeaUpdate
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "could not decode tea updates: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		writeErr(w, http.StatusBadRequest, "no tea updates")
+		return
+	}
+	for i, u := range data {
+		if u.OldTea == nil || u.NewTea == nil {
+			writeErr(w, http.StatusBadRequest, "oldTea and newTea are required at %v", i)
+			return
+		}
+	}
+	result, err := setTeas(data)
+	if err != nil {
+		writeErr(w, neoErrStatus(err), "could not set teas: %v", err)
+		return
+	}
+	writeJson(w, result)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/nspcc-dev/neo-go/pkg/config/netmode"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	neo "github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm"
+	"github.com/nspcc-dev/neo-go/pkg/wallet"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strings"
+)
+
+var errNeoMultisigOwner = errors.New("the NEO owner is a multi-signature account, a single key is needed")
+
+// loadNeoOwner returns the owner account from the NEP-6 wallet NEO_WALLET, or from NEO_PRIVATE_KEY if no wallet
+// is set. A multi-signature account returns one account per local key, ordered as the keys in its script, so the
+// signatures can be added one after the other.
+func loadNeoOwner(o *Opts) ([]*wallet.Account, error) {
+	if o.NeoWallet == "" {
+		key, err := keys.NewPrivateKeyFromWIF(o.NEO.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		return []*wallet.Account{wallet.NewAccountFromPrivateKey(key)}, nil
+	}
+
+	w, err := wallet.NewWalletFromFile(o.NeoWallet)
+	if err != nil {
+		return nil, fmt.Errorf("could not read wallet: %w", err)
+	}
+	password, err := os.ReadFile(o.NeoWalletPasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read wallet password: %w", err)
+	}
+
+	owner, err := neoWalletOwner(w, o.NeoWalletAccount)
+	if err != nil {
+		return nil, err
+	}
+	var accs []*wallet.Account
+	for _, a := range w.Accounts {
+		if a.Address != owner || a.EncryptedWIF == "" {
+			continue
+		}
+		err = a.Decrypt(strings.TrimRight(string(password), "\r\n"), w.Scrypt)
+		if err != nil {
+			return nil, fmt.Errorf("could not unlock %v: %w", a.Address, err)
+		}
+		accs = append(accs, a)
+	}
+	if len(accs) == 0 {
+		return nil, fmt.Errorf("no key for %v in the wallet", owner)
+	}
+
+	m, pubs, ok := vm.ParseMultiSigContract(accs[0].Contract.Script)
+	if !ok {
+		return accs[:1], nil
+	}
+	index := func(a *wallet.Account) int {
+		for i, p := range pubs {
+			if bytes.Equal(p, a.PrivateKey().PublicKey().Bytes()) {
+				return i
+			}
+		}
+		return len(pubs)
+	}
+	sort.SliceStable(accs, func(i, j int) bool { return index(accs[i]) < index(accs[j]) })
+	if len(accs) < m {
+		log.Printf("%v needs %v signatures, the wallet has %v keys, transactions are co-signed offline", owner, m, len(accs))
+		return accs, nil
+	}
+	return accs[:m], nil
+}
+
+// neoCosignRequired is true if the owner is a multi-signature account with fewer local keys than signatures needed
+func neoCosignRequired() bool {
+	if len(neoOwners) == 0 {
+		return false
+	}
+	m, _, ok := vm.ParseMultiSigContract(neoOwners[0].Contract.Script)
+	return ok && len(neoOwners) < m
+}
+
+// neoWalletOwner returns the address of the account, the default account of the wallet or the first one
+func neoWalletOwner(w *wallet.Wallet, account string) (string, error) {
+	if account != "" {
+		if _, err := address.StringToUint160(account); err != nil {
+			return "", fmt.Errorf("invalid NEO3 address %v: %w", account, err)
+		}
+		return account, nil
+	}
+	for _, a := range w.Accounts {
+		if a.Default {
+			return a.Address, nil
+		}
+	}
+	if len(w.Accounts) == 0 {
+		return "", errors.New("the wallet has no accounts")
+	}
+	return w.Accounts[0].Address, nil
+}
+
+// neoOwnerKey returns the key of a single-signature owner, used for the withdraw signatures and as contract owner
+func neoOwnerKey() (*keys.PrivateKey, error) {
+	if len(neoOwners) == 0 {
+		return nil, &NeoError{Code: NeoErrConfig, Err: errors.New("NEO owner is not loaded")}
+	}
+	if len(neoOwners) > 1 || !vm.IsSignatureContract(neoOwners[0].Contract.Script) {
+		return nil, &NeoError{Code: NeoErrConfig, Err: errNeoMultisigOwner}
+	}
+	return neoOwners[0].PrivateKey(), nil
+}
+
+// checkNeoOwnerContract refuses a multi-signature owner for PayoutNeo. The contract checks the witness of the owner
+// public key stored at deployment, which a multi-signature account can never provide.
+func checkNeoOwnerContract(c *neo.Client, contract string) error {
+	if _, err := neoOwnerKey(); !errors.Is(err, errNeoMultisigOwner) || contract == "" {
+		return nil
+	}
+	h, err := util.Uint160DecodeStringLE(contract)
+	if err != nil {
+		return &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("invalid contract %v: %w", contract, err)}
+	}
+	cs, err := c.GetContractStateByHash(h)
+	if err != nil {
+		return neoRPCError("get contract state", err)
+	}
+	if cs.Manifest.Name == neoContractName {
+		return &NeoError{Code: NeoErrConfig, Err: fmt.Errorf("%w, %v checks the witness of the owner public key", errNeoMultisigOwner, neoContractName)}
+	}
+	return nil
+}
+
+// signNeoTx adds the witness of the account, co-signed by all local keys if it is the multi-signature owner
+func signNeoTx(net netmode.Magic, acc *wallet.Account, tx *transaction.Transaction) error {
+	accs := []*wallet.Account{acc}
+	if len(neoOwners) > 0 && neoOwners[0].ScriptHash().Equals(acc.ScriptHash()) {
+		if neoCosignRequired() {
+			return &NeoError{Code: NeoErrConfig, Err: errors.New("the NEO owner needs offline co-signing")}
+		}
+		accs = neoOwners
+	}
+	for _, a := range accs {
+		if err := a.SignTx(net, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"os"
+	"time"
+)
+
+const (
+	RuleDenylist                = "denylist"
+	RuleAllowlist               = "allowlist"
+	RuleMaxIncreasePerSignature = "maxIncreasePerSignature"
+	RuleMaxIncreasePerUserDay   = "maxIncreasePerUserDay"
+	RuleMaxDailyVolume          = "maxDailyVolume"
+	RuleApprovalThreshold       = "approvalThreshold"
+)
+
+// Policy is the signing policy loaded from POLICY_FILE. The increase of a signature is the amount above the highest
+// amount already signed or payed out for this user. Limits that are not set are not checked. The limits apply to each
+// chain on its own, as the amounts are in the native currency of the chain. On NEO and Tezos the user is the account
+// address.
+type Policy struct {
+	// MaxIncreasePerSignature is the largest increase of a single signature
+	MaxIncreasePerSignature *big.Int `json:"maxIncreasePerSignature"`
+	// MaxIncreasePerUserDay is the largest sum of increases per user within 24h
+	MaxIncreasePerUserDay *big.Int `json:"maxIncreasePerUserDay"`
+	// MaxDailyVolume is the largest sum of increases of all users within 24h
+	MaxDailyVolume *big.Int `json:"maxDailyVolume"`
+	// Allowlist contains the only userIds and addresses that can be signed for, if not empty
+	Allowlist []string `json:"allowlist"`
+	// Denylist contains the userIds and addresses that are never signed for
+	Denylist []string `json:"denylist"`
+	// ApprovalThreshold is the increase above which APPROVALS_REQUIRED admins have to approve
+	ApprovalThreshold *big.Int `json:"approvalThreshold"`
+}
+
+// ErrPolicy is returned if a policy rule rejects a signature, Rule is the name of the rule
+type ErrPolicy struct {
+	Rule string
+	Msg  string
+}
+
+func (e *ErrPolicy) Error() string {
+	return fmt.Sprintf("ERR-04,policy %v: %v", e.Rule, e.Msg)
+}
+
+func loadPolicy(filename string) (*Policy, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse policy %v: %w", filename, err)
+	}
+	normalizeUserIds(p.Allowlist)
+	normalizeUserIds(p.Denylist)
+	return p, nil
+}
+
+// normalizeUserIds writes the userIds of the list in the form of uuid.String, addresses are kept as they are
+func normalizeUserIds(list []string) {
+	for i, v := range list {
+		if id, err := uuid.Parse(v); err == nil {
+			list[i] = id.String()
+		}
+	}
+}
+
+// check runs all rules for the entry with its increase. It runs within the ledger transaction, so the daily sums
+// include all signatures issued before on this chain. If approved is set, the approval threshold is not checked.
+func (p *Policy) check(tx *bolt.Tx, e *LedgerEntry, approved bool) error {
+	if p == nil {
+		return nil
+	}
+	user := policyUser(e)
+	if containsString(p.Denylist, user) {
+		return &ErrPolicy{Rule: RuleDenylist, Msg: fmt.Sprintf("%v is denied", user)}
+	}
+	if len(p.Allowlist) > 0 && !containsString(p.Allowlist, user) {
+		return &ErrPolicy{Rule: RuleAllowlist, Msg: fmt.Sprintf("%v is not allowed", user)}
+	}
+	increase := e.Increase
+	if p.MaxIncreasePerSignature != nil && increase.Cmp(p.MaxIncreasePerSignature) > 0 {
+		return &ErrPolicy{Rule: RuleMaxIncreasePerSignature, Msg: fmt.Sprintf("increase %v > %v", increase, p.MaxIncreasePerSignature)}
+	}
+
+	if p.MaxIncreasePerUserDay != nil || p.MaxDailyVolume != nil {
+		userSum, totalSum, err := dailyIncreases(tx, e, timeNow().Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		userSum.Add(userSum, increase)
+		totalSum.Add(totalSum, increase)
+		if p.MaxIncreasePerUserDay != nil && userSum.Cmp(p.MaxIncreasePerUserDay) > 0 {
+			return &ErrPolicy{Rule: RuleMaxIncreasePerUserDay, Msg: fmt.Sprintf("daily increase %v > %v", userSum, p.MaxIncreasePerUserDay)}
+		}
+		if p.MaxDailyVolume != nil && totalSum.Cmp(p.MaxDailyVolume) > 0 {
+			return &ErrPolicy{Rule: RuleMaxDailyVolume, Msg: fmt.Sprintf("daily volume %v > %v", totalSum, p.MaxDailyVolume)}
+		}
+	}
+
+	if !approved && p.ApprovalThreshold != nil && increase.Cmp(p.ApprovalThreshold) > 0 {
+		return &ErrPolicy{Rule: RuleApprovalThreshold, Msg: fmt.Sprintf("increase %v > %v needs admin approval", increase, p.ApprovalThreshold)}
+	}
+	return nil
+}
+
+// dailyIncreases sums up the increases of the ledger on the chain of the entry since the given time, for the user of
+// the entry and for all users
+func dailyIncreases(tx *bolt.Tx, entry *LedgerEntry, since time.Time) (*big.Int, *big.Int, error) {
+	chainId := ledgerChainId(entry)
+	user := policyUser(entry)
+	userSum := new(big.Int)
+	totalSum := new(big.Int)
+	c := tx.Bucket(bucketLedger).Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		var e LedgerEntry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return nil, nil, err
+		}
+		if e.CreatedAt.Before(since) {
+			break
+		}
+		if e.Increase == nil || e.Chain != entry.Chain || ledgerChainId(&e) != chainId {
+			continue
+		}
+		totalSum.Add(totalSum, e.Increase)
+		if policyUser(&e) == user {
+			userSum.Add(userSum, e.Increase)
+		}
+	}
+	return userSum, totalSum, nil
+}
+
+// policyUser is the userId of the entry, or the account on NEO and Tezos
+func policyUser(e *LedgerEntry) string {
+	if e.Chain != "" {
+		return e.Account
+	}
+	return e.UserId.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
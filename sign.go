@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/google/uuid"
+	"math/big"
+)
+
+const (
+	// SignModeLegacy signs keccak256(userId ‖ '#' ‖ uint256(amount)) as checked by PayoutEth.sol, or the prefixed
+	// keccak256(userId ‖ uint256(amount)) for the embedded legacy contract. The recipient is not part of the
+	// signature, so it can be front-run. Only use it for contracts already deployed.
+	SignModeLegacy = "legacy"
+	// SignModeBound signs keccak256(userId ‖ '#' ‖ uint256(amount) ‖ '#' ‖ dev) as checked by PayoutEthBound.sol
+	SignModeBound = "bound"
+	// SignModeEip712 signs EIP-712 typed data as checked by PayoutEthEip712.sol
+	SignModeEip712 = "eip712"
+
+	// ethSignedMessagePrefix is applied by the embedded legacy contract, it labels the 32 bytes hash with the length 66
+	ethSignedMessagePrefix = "\x19Ethereum Signed Message:\n66"
+)
+
+// eip712Types needs to match the WITHDRAW_TYPEHASH and DOMAIN_TYPEHASH in PayoutEthEip712.sol
+var eip712Types = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"Withdraw": {
+		{Name: "dev", Type: "address"},
+		{Name: "userId", Type: "bytes32"},
+		{Name: "totalPayOut", Type: "uint256"},
+	},
+}
+
+func signPayout(c *ClientETH, userId uuid.UUID, amount *big.Int, dev common.Address) (*Signature, error) {
+	if amount == nil || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %v", amount)
+	}
+	if c.signMode != SignModeLegacy && dev == (common.Address{}) {
+		return nil, fmt.Errorf("payout address is required in sign mode %v", c.signMode)
+	}
+
+	hashRaw, typedData, err := payoutHash(c, userId, amount, dev)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	if typedData != nil {
+		signature, err = c.signer.SignTypedData(*typedData)
+	} else {
+		signature, err = c.signer.SignHash(contractDigest(c, hashRaw))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signer error %v", err)
+	}
+
+	//https://ethereum.stackexchange.com/questions/45580/validating-go-ethereum-key-signature-with-ecrecover
+	return &Signature{
+		Raw:       signature,
+		Hash:      bytes32(hashRaw),
+		R:         bytes32(signature[:32]),
+		S:         bytes32(signature[32:64]),
+		V:         uint8(int(signature[64])) + 27, // Yes add 27, weird Ethereum quirk
+		TypedData: typedData,
+		Dev:       dev,
+		SignMode:  c.signMode,
+		ChainId:   c.chainId.Int64(),
+	}, nil
+}
+
+// payoutHash returns the hash to sign for the sign mode of the chain. For eip712 the typed data is returned as well.
+func payoutHash(c *ClientETH, userId uuid.UUID, amount *big.Int, dev common.Address) ([]byte, *apitypes.TypedData, error) {
+	switch c.signMode {
+	case SignModeLegacy:
+		if c.prefixed {
+			return prefixedLegacyHash(userId, amount), nil, nil
+		}
+		return legacyHash(userId, amount), nil, nil
+	case SignModeBound:
+		return boundHash(userId, amount, dev), nil, nil
+	case SignModeEip712:
+		typedData := eip712TypedData(userId, amount, dev, c.chainId, c.address)
+		h, _, err := apitypes.TypedDataAndHash(*typedData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("typed data error %v", err)
+		}
+		return h, typedData, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown sign mode %v", c.signMode)
+	}
+}
+
+// contractDigest returns the digest the contract passes to ecrecover for the given hash. The embedded legacy contract
+// applies the Ethereum signed message prefix, the other contracts use the hash as is.
+func contractDigest(c *ClientETH, hashRaw []byte) []byte {
+	if c.prefixed {
+		return crypto.Keccak256([]byte(ethSignedMessagePrefix), hashRaw)
+	}
+	return hashRaw
+}
+
+// recoverSigner returns the address that created the signature v, r, s over the digest
+func recoverSigner(digest []byte, v uint8, r common.Hash, s common.Hash) (common.Address, error) {
+	if v != 27 && v != 28 {
+		return common.Address{}, fmt.Errorf("invalid v %v, must be 27 or 28", v)
+	}
+	sig := make([]byte, 65)
+	copy(sig[:32], r[:])
+	copy(sig[32:64], s[:])
+	sig[64] = v - 27
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// userIdBytes32 converts the userId to the bytes32 used by the contract, the UUID is right-padded with zeros
+func userIdBytes32(userId uuid.UUID) [32]byte {
+	b, _ := userId.MarshalBinary()
+	return bytes32(b)
+}
+
+func legacyHash(userId uuid.UUID, amount *big.Int) []byte {
+	b := userIdBytes32(userId)
+	//U256Bytes modifies the value, so use a copy
+	return crypto.Keccak256(b[:], []byte{'#'}, math.U256Bytes(new(big.Int).Set(amount)))
+}
+
+// prefixedLegacyHash is the hash of the embedded legacy contract, keccak256(userId ‖ uint256(amount)) without '#'
+func prefixedLegacyHash(userId uuid.UUID, amount *big.Int) []byte {
+	b := userIdBytes32(userId)
+	return crypto.Keccak256(b[:], math.U256Bytes(new(big.Int).Set(amount)))
+}
+
+func boundHash(userId uuid.UUID, amount *big.Int, dev common.Address) []byte {
+	b := userIdBytes32(userId)
+	return crypto.Keccak256(b[:], []byte{'#'}, math.U256Bytes(new(big.Int).Set(amount)), []byte{'#'}, dev.Bytes())
+}
+
+// eip712TypedData creates the typed data of a withdrawal. The domain binds the signature to the chain and the
+// contract, so it cannot be replayed elsewhere.
+func eip712TypedData(userId uuid.UUID, amount *big.Int, dev common.Address, chainId *big.Int, contract common.Address) *apitypes.TypedData {
+	b := userIdBytes32(userId)
+	return &apitypes.TypedData{
+		Types:       eip712Types,
+		PrimaryType: "Withdraw",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "PayoutEth",
+			Version:           "1",
+			ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainId)),
+			VerifyingContract: contract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"dev":         dev.Hex(),
+			"userId":      hexutil.Encode(b[:]),
+			"totalPayOut": (*math.HexOrDecimal256)(new(big.Int).Set(amount)),
+		},
+	}
+}
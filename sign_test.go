@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var ether = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// newSimulatedEthClient deploys the binary on a simulated chain, funds the contract with 10 ether and returns the
+// client for it, registered as the ETH chain
+func newSimulatedEthClient(t *testing.T, signMode string, bin string) (*ClientETH, *backends.SimulatedBackend) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &keySigner{privateKey: key}
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		signer.Address(): {Balance: new(big.Int).Mul(big.NewInt(100), ether)},
+	}, 10_000_000)
+	t.Cleanup(func() { backend.Close() })
+
+	c := &ClientETH{
+		name:        "eth",
+		signer:      signer,
+		signMode:    signMode,
+		fromAddress: signer.Address(),
+		chainId:     backend.Blockchain().Config().ChainID,
+	}
+	parsed, err := PayoutEthMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.address, _, c.contract, err = bind.DeployContract(c.transactOpts(), *parsed, common.FromHex(bin), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	code, err := backend.CodeAt(context.Background(), c.address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.prefixed = isPrefixedEthCode(crypto.Keccak256Hash(code))
+
+	fund := c.transactOpts()
+	fund.Value = new(big.Int).Mul(big.NewInt(10), ether)
+	if _, err = c.contract.RawTransact(fund, nil); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	ethClient, opts = c, &Opts{}
+	t.Cleanup(func() { ethClient, opts = nil, nil })
+	return c, backend
+}
+
+// withdrawSigned sends withdraw with the signature and returns the balance of dev afterwards. Anyone can send it, so
+// the owner pays the gas.
+func withdrawSigned(t *testing.T, c *ClientETH, backend *backends.SimulatedBackend, userId uuid.UUID, amount *big.Int, dev common.Address, s *Signature) *big.Int {
+	t.Helper()
+	opts := c.transactOpts()
+	opts.GasLimit = 200_000
+	tx, err := c.contract.Transact(opts, "withdraw", dev, userIdBytes32(userId), amount, s.V, s.R, s.S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	receipt, err := backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != 1 {
+		t.Fatalf("withdraw reverted")
+	}
+	balance, err := backend.BalanceAt(context.Background(), dev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func verifySignature(t *testing.T, userId uuid.UUID, amount *big.Int, dev common.Address, s *Signature) VerifyResponse {
+	t.Helper()
+	body, err := json.Marshal(VerifyRequest{UserId: userId, TotalPayedOut: amount, Address: dev, V: s.V, R: s.R, S: s.S})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	verify(rec, httptest.NewRequest(http.MethodPost, "/admin/verify", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify returned %v: %v", rec.Code, rec.Body.String())
+	}
+	var res VerifyResponse
+	if err = json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestSignPayout(t *testing.T) {
+	for _, signMode := range []string{SignModeLegacy, SignModeBound, SignModeEip712} {
+		t.Run(signMode, func(t *testing.T) {
+			c, backend := newSimulatedEthClient(t, signMode, payoutEthBins[signMode])
+			code, err := backend.CodeAt(context.Background(), c.address, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			codeHash := crypto.Keccak256Hash(code)
+			if !isKnownEthCode(signMode, codeHash) {
+				t.Fatalf("deployed code is not the embedded %v contract", signMode)
+			}
+			if err = c.checkSignMode(codeHash); err != nil {
+				t.Fatal(err)
+			}
+
+			userId := uuid.New()
+			amount := big.NewInt(12345)
+			dev := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+
+			s, err := signPayout(c, userId, amount, dev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := verifySignature(t, userId, amount, dev, s)
+			if !res.Valid || res.Signer != c.fromAddress {
+				t.Fatalf("signature not valid: %+v", res)
+			}
+			switch {
+			case signMode == SignModeLegacy && res.Digest != crypto.Keccak256Hash([]byte(ethSignedMessagePrefix), res.Hash[:]):
+				t.Fatalf("digest %v is not the prefixed hash %v", res.Digest, res.Hash)
+			case signMode == SignModeBound && res.Digest != res.Hash:
+				t.Fatalf("digest %v is not the hash %v", res.Digest, res.Hash)
+			}
+			if balance := withdrawSigned(t, c, backend, userId, amount, dev, s); balance.Cmp(amount) != 0 {
+				t.Fatalf("dev balance is %v, expected %v", balance, amount)
+			}
+		})
+	}
+}
+
+func TestCheckSignModeMismatch(t *testing.T) {
+	c := &ClientETH{name: "eth", signMode: SignModeLegacy}
+	opts = &Opts{}
+	t.Cleanup(func() { opts = nil })
+	bound := crypto.Keccak256Hash(ethRuntime(payoutEthBins[SignModeBound]))
+	if err := c.checkSignMode(bound); !errors.Is(err, errChainConfig) {
+		t.Fatalf("expected a configuration error for the bound contract in legacy mode, got %v", err)
+	}
+	if isKnownEthCode(SignModeLegacy, bound) {
+		t.Fatal("bound contract is accepted in legacy mode")
+	}
+	//the sign mode of a contract from ETH_CODE_HASHES is not known
+	if err := c.checkSignMode(common.Hash{1}); err != nil {
+		t.Fatal(err)
+	}
+}
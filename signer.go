@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const (
+	// SignerKey uses the raw hex key from ETH_PRIVATE_KEY, only allowed for local and dev
+	SignerKey = "key"
+	// SignerKeystore uses an encrypted go-ethereum JSON keystore file
+	SignerKeystore = "keystore"
+	// SignerExternal uses an external signer with a Clef compatible JSON-RPC API
+	SignerExternal = "external"
+)
+
+var errRawHashNotSupported = errors.New("external signer cannot sign a raw hash, use sign mode eip712")
+
+// Signer creates the signatures for payouts and the transactions sent to the chain. Signatures are returned in
+// the [R || S || V] format with V being 0 or 1.
+type Signer interface {
+	Address() common.Address
+	SignHash(hash []byte) ([]byte, error)
+	SignTypedData(typedData apitypes.TypedData) ([]byte, error)
+	SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error)
+}
+
+func newSigner(o *Opts) (Signer, error) {
+	switch o.EthSigner {
+	case SignerKey:
+		if !debug {
+			return nil, fmt.Errorf("signer %v is only allowed for local use, env is %v", SignerKey, o.Env)
+		}
+		privateKey, err := crypto.HexToECDSA(o.Ethereum.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &keySigner{privateKey: privateKey}, nil
+	case SignerKeystore:
+		return newKeystoreSigner(o.EthKeystore, o.EthKeystorePasswordFile)
+	case SignerExternal:
+		if o.EthSignMode != SignModeEip712 {
+			return nil, errRawHashNotSupported
+		}
+		return newExternalSigner(o.EthExternalSigner, o.EthSignerAddress)
+	default:
+		return nil, fmt.Errorf("unknown signer %v", o.EthSigner)
+	}
+}
+
+// newEthChainSigner returns the signer of an additional EVM chain, the ETH signer if it has no own key
+func newEthChainSigner(ec EthChain) (Signer, error) {
+	switch {
+	case ec.Keystore != "":
+		return newKeystoreSigner(ec.Keystore, ec.KeystorePasswordFile)
+	case ec.Blockchain.PrivateKey != "":
+		if !debug {
+			return nil, fmt.Errorf("private key of %v is only allowed for local use, env is %v", ec.Name, opts.Env)
+		}
+		privateKey, err := crypto.HexToECDSA(ec.Blockchain.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &keySigner{privateKey: privateKey}, nil
+	default:
+		if _, ok := ethSigner.(*externalSigner); ok && ec.SignMode != SignModeEip712 {
+			return nil, errRawHashNotSupported
+		}
+		return ethSigner, nil
+	}
+}
+
+// keySigner signs with a private key held in memory
+type keySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func newKeystoreSigner(keystoreFile string, passwordFile string) (*keySigner, error) {
+	keyJson, err := os.ReadFile(keystoreFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keystore: %w", err)
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keystore password: %w", err)
+	}
+	key, err := keystore.DecryptKey(keyJson, strings.TrimRight(string(password), "\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("could not unlock keystore: %w", err)
+	}
+	return &keySigner{privateKey: key.PrivateKey}, nil
+}
+
+func (k *keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(k.privateKey.PublicKey)
+}
+
+func (k *keySigner) SignHash(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, k.privateKey)
+}
+
+func (k *keySigner) SignTypedData(typedData apitypes.TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash, k.privateKey)
+}
+
+func (k *keySigner) SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainId), k.privateKey)
+}
+
+// externalSigner delegates to a Clef compatible signer, the key never leaves the signer
+type externalSigner struct {
+	signer  *external.ExternalSigner
+	client  *rpc.Client
+	account accounts.Account
+}
+
+func newExternalSigner(endpoint string, signerAddress string) (*externalSigner, error) {
+	signer, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client, err := rpc.DialContext(context.Background(), endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	accs := signer.Accounts()
+	if len(accs) == 0 {
+		return nil, fmt.Errorf("external signer %v has no accounts", endpoint)
+	}
+	account := accs[0]
+	if signerAddress != "" {
+		account = accounts.Account{Address: common.HexToAddress(signerAddress)}
+		if !signer.Contains(account) {
+			return nil, fmt.Errorf("external signer %v has no account %v", endpoint, signerAddress)
+		}
+	}
+	return &externalSigner{signer: signer, client: client, account: account}, nil
+}
+
+func (e *externalSigner) Address() common.Address {
+	return e.account.Address
+}
+
+func (e *externalSigner) SignHash([]byte) ([]byte, error) {
+	return nil, errRawHashNotSupported
+}
+
+func (e *externalSigner) SignTypedData(typedData apitypes.TypedData) ([]byte, error) {
+	var res hexutil.Bytes
+	addr := common.NewMixedcaseAddress(e.account.Address)
+	err := e.client.Call(&res, "account_signTypedData", &addr, typedData)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 65 {
+		return nil, fmt.Errorf("invalid signature length %v", len(res))
+	}
+	//Clef returns V as 27/28
+	if res[64] == 27 || res[64] == 28 {
+		res[64] -= 27
+	}
+	return res, nil
+}
+
+func (e *externalSigner) SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	return e.signer.SignTx(e.account, tx, chainId)
+}
@@ -0,0 +1,844 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/base58"
+	"golang.org/x/crypto/blake2b"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// base58 prefixes of the Tezos encodings
+var (
+	xtzPrefixEdsk   = []byte{13, 15, 58, 7}
+	xtzPrefixEdsk64 = []byte{43, 246, 78, 7}
+	xtzPrefixSpsk   = []byte{17, 162, 224, 201}
+	xtzPrefixEdpk   = []byte{13, 15, 37, 217}
+	xtzPrefixSppk   = []byte{3, 254, 226, 86}
+	xtzPrefixTz1    = []byte{6, 161, 159}
+	xtzPrefixTz2    = []byte{6, 161, 161}
+	xtzPrefixTz3    = []byte{6, 161, 164}
+	xtzPrefixKT1    = []byte{2, 90, 121}
+	xtzPrefixEdsig  = []byte{9, 245, 205, 134, 18}
+	xtzPrefixSpsig  = []byte{13, 115, 101, 19, 63}
+	xtzPrefixBlock  = []byte{1, 52}
+	xtzPrefixOp     = []byte{5, 116}
+	xtzPrefixChain  = []byte{87, 82, 0}
+	xtzPrefixExpr   = []byte{13, 44, 64, 27}
+)
+
+// errXtzNotFound is returned by the RPC for a missing value, e.g., a big_map key
+var errXtzNotFound = errors.New("not found")
+
+const (
+	xtzBatchEntrypoint   = "batchPayout"
+	xtzDefaultEntrypoint = "default"
+	xtzTagTransaction    = 108
+	xtzWatermarkOp       = 3
+	xtzPollInterval      = 2 * time.Second
+	xtzPollTimeout       = 5 * time.Minute
+	//signature for the simulation, it is not checked
+	xtzDummySignature = "edsigtXomBKi5CTRf5cjATJWSyaRvhfYNHqSUGrn4SdbYRcGwQrUGjzEfQDTuqHhuA8b2d8NarZjz8TRf65WkpQmo423BtomS8Q"
+)
+
+// ClientXTZ talks to a Tezos node over its RPC. Transactions are forged locally, so the node cannot change what
+// the owner signs.
+type ClientXTZ struct {
+	url      string
+	c        *http.Client
+	key      *xtzKey
+	contract string
+	chainId  string
+	//teasId is the id of the big_map with the payed out tea of the accounts
+	teasId string
+}
+
+// xtzKey is an ed25519 (edsk) or secp256k1 (spsk) key
+type xtzKey struct {
+	ed ed25519.PrivateKey
+	sp *ecdsa.PrivateKey
+}
+
+func parseXtzKey(s string) (*xtzKey, error) {
+	b, err := base58.CheckDecode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Tezos key: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(b, xtzPrefixEdsk) && len(b) == len(xtzPrefixEdsk)+ed25519.SeedSize:
+		return &xtzKey{ed: ed25519.NewKeyFromSeed(b[len(xtzPrefixEdsk):])}, nil
+	case bytes.HasPrefix(b, xtzPrefixEdsk64) && len(b) == len(xtzPrefixEdsk64)+ed25519.PrivateKeySize:
+		return &xtzKey{ed: ed25519.NewKeyFromSeed(b[len(xtzPrefixEdsk64) : len(xtzPrefixEdsk64)+ed25519.SeedSize])}, nil
+	case bytes.HasPrefix(b, xtzPrefixSpsk) && len(b) == len(xtzPrefixSpsk)+32:
+		sp, err := crypto.ToECDSA(b[len(xtzPrefixSpsk):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Tezos secp256k1 key: %w", err)
+		}
+		return &xtzKey{sp: sp}, nil
+	}
+	return nil, errors.New("unsupported Tezos key, edsk or spsk expected")
+}
+
+func (k *xtzKey) publicKey() []byte {
+	if k.ed != nil {
+		return k.ed.Public().(ed25519.PublicKey)
+	}
+	return crypto.CompressPubkey(&k.sp.PublicKey)
+}
+
+func (k *xtzKey) PublicKey() string {
+	if k.ed != nil {
+		return base58.CheckEncode(append(append([]byte{}, xtzPrefixEdpk...), k.publicKey()...))
+	}
+	return base58.CheckEncode(append(append([]byte{}, xtzPrefixSppk...), k.publicKey()...))
+}
+
+func (k *xtzKey) Address() string {
+	h := blake2b160(k.publicKey())
+	if k.ed != nil {
+		return base58.CheckEncode(append(append([]byte{}, xtzPrefixTz1...), h...))
+	}
+	return base58.CheckEncode(append(append([]byte{}, xtzPrefixTz2...), h...))
+}
+
+// sign returns the raw signature over blake2b(msg), as checked by CHECK_SIGNATURE and the protocol
+func (k *xtzKey) sign(msg []byte) ([]byte, error) {
+	h := blake2b.Sum256(msg)
+	if k.ed != nil {
+		return ed25519.Sign(k.ed, h[:]), nil
+	}
+	sig, err := crypto.Sign(h[:], k.sp)
+	if err != nil {
+		return nil, err
+	}
+	return sig[:64], nil
+}
+
+func (k *xtzKey) encodeSignature(sig []byte) string {
+	if k.ed != nil {
+		return base58.CheckEncode(append(append([]byte{}, xtzPrefixEdsig...), sig...))
+	}
+	return base58.CheckEncode(append(append([]byte{}, xtzPrefixSpsig...), sig...))
+}
+
+func blake2b160(b []byte) []byte {
+	h, _ := blake2b.New(20, nil)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// xtzContractId encodes an address in the 22 bytes binary form, as used for PACK and in operations
+func xtzContractId(addr string) ([]byte, error) {
+	b, err := base58.CheckDecode(addr)
+	if err != nil || len(b) != 23 {
+		return nil, fmt.Errorf("invalid Tezos address %v", addr)
+	}
+	p, h := b[:3], b[3:]
+	switch {
+	case bytes.Equal(p, xtzPrefixTz1):
+		return append([]byte{0, 0}, h...), nil
+	case bytes.Equal(p, xtzPrefixTz2):
+		return append([]byte{0, 1}, h...), nil
+	case bytes.Equal(p, xtzPrefixTz3):
+		return append([]byte{0, 2}, h...), nil
+	case bytes.Equal(p, xtzPrefixKT1):
+		return append(append([]byte{1}, h...), 0), nil
+	}
+	return nil, fmt.Errorf("invalid Tezos address %v", addr)
+}
+
+// zarith encodes a natural number, 7 bits per byte
+func zarith(n *big.Int) []byte {
+	var out []byte
+	v := new(big.Int).Set(n)
+	for {
+		b := byte(new(big.Int).And(v, big.NewInt(0x7f)).Uint64())
+		v.Rsh(v, 7)
+		if v.Sign() == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// michelineNat encodes a non-negative Micheline int, the first byte holds 6 bits and the sign
+func michelineNat(n *big.Int) []byte {
+	v := new(big.Int).Set(n)
+	first := byte(new(big.Int).And(v, big.NewInt(0x3f)).Uint64())
+	v.Rsh(v, 6)
+	if v.Sign() == 0 {
+		return []byte{0x00, first}
+	}
+	return append([]byte{0x00, first | 0x80}, zarith(v)...)
+}
+
+func michelineBytes(b []byte) []byte {
+	out := []byte{0x0a, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[1:], uint32(len(b)))
+	return append(out, b...)
+}
+
+// michelineAddress encodes an address as its binary form
+func michelineAddress(addr string) ([]byte, error) {
+	id, err := xtzContractId(addr)
+	if err != nil {
+		return nil, err
+	}
+	return michelineBytes(id), nil
+}
+
+func michelinePair(left []byte, right []byte) []byte {
+	return append(append([]byte{0x07, 0x07}, left...), right...)
+}
+
+// xtzChainId decodes the base58 chain id to its 4 bytes
+func xtzChainId(chainId string) ([]byte, error) {
+	b, err := base58.CheckDecode(chainId)
+	if err != nil || len(b) != len(xtzPrefixChain)+4 || !bytes.HasPrefix(b, xtzPrefixChain) {
+		return nil, fmt.Errorf("invalid Tezos chain id %v", chainId)
+	}
+	return b[len(xtzPrefixChain):], nil
+}
+
+// xtzPermit is PACK (Pair (Pair chain_id contract) (Pair account tea)), the bytes the contract checks the signature
+// of the owner against. The chain id and the contract keep a permit from being used on another chain or contract.
+func xtzPermit(chainId string, contract string, account string, tea *big.Int) ([]byte, error) {
+	chain, err := xtzChainId(chainId)
+	if err != nil {
+		return nil, err
+	}
+	self, err := michelineAddress(contract)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := michelineAddress(account)
+	if err != nil {
+		return nil, err
+	}
+	p := michelinePair(michelinePair(michelineBytes(chain), self), michelinePair(acc, michelineNat(tea)))
+	return append([]byte{0x05}, p...), nil
+}
+
+// signWithdraw signs the permit of the account for withdraw of the contract
+func (c *ClientXTZ) signWithdraw(account string, tea *big.Int) (string, []byte, error) {
+	permit, err := xtzPermit(c.chainId, c.contract, account, tea)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := c.key.sign(permit)
+	if err != nil {
+		return "", nil, err
+	}
+	return c.key.encodeSignature(sig), permit, nil
+}
+
+// signWithdrawAndRecord signs the permit for withdraw with the ledger and policy checks of signAndRecord. The tea
+// has to be above the tea the contract already payed out to the account, see paidOut.
+func (c *ClientXTZ) signWithdrawAndRecord(account string, tea *big.Int, subject string, approved bool) (*XtzSignature, error) {
+	if c == nil {
+		return nil, errors.New("Tezos is not enabled")
+	}
+	if tea == nil || tea.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: invalid tea %v", errInvalidRequest, tea)
+	}
+	permit, err := xtzPermit(c.chainId, c.contract, account, tea)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	res := &XtzSignature{Address: account, Tea: tea}
+	e := &LedgerEntry{
+		Chain:   "xtz",
+		Account: account,
+		Amount:  tea,
+		Hash:    blake2b.Sum256(permit),
+		Owner:   c.key.Address(),
+		Subject: subject,
+	}
+	res.Claimable, err = signAccountAndRecord(e, approved, func() (*big.Int, error) {
+		return c.paidOut(account)
+	}, func() error {
+		var err error
+		res.Signature, res.Packed, err = c.signWithdraw(account, tea)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// xtzScriptExprHash is the expr hash of the packed address, the key of the address in a big_map
+func xtzScriptExprHash(addr string) (string, error) {
+	acc, err := michelineAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	h := blake2b.Sum256(append([]byte{0x05}, acc...))
+	return base58.CheckEncode(append(append([]byte{}, xtzPrefixExpr...), h[:]...)), nil
+}
+
+// paidOut reads the tea the contract payed out to the account from the teas big_map, 0 if it has no entry
+func (c *ClientXTZ) paidOut(account string) (*big.Int, error) {
+	key, err := xtzScriptExprHash(account)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Int string `json:"int"`
+	}
+	err = c.get("/chains/main/blocks/head/context/big_maps/"+c.teasId+"/"+key, &v)
+	if errors.Is(err, errXtzNotFound) {
+		return new(big.Int), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tea, ok := new(big.Int).SetString(v.Int, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid tea %v of %v", v.Int, account)
+	}
+	return tea, nil
+}
+
+// readTeasId reads the id of the teas big_map from the storage of the contract, Pair owner teas
+func (c *ClientXTZ) readTeasId() error {
+	var storage struct {
+		Args []struct {
+			Int string `json:"int"`
+		} `json:"args"`
+	}
+	err := c.get("/chains/main/blocks/head/context/contracts/"+c.contract+"/storage", &storage)
+	if err != nil {
+		return err
+	}
+	if len(storage.Args) != 2 || storage.Args[1].Int == "" {
+		return fmt.Errorf("storage of the Tezos contract %v is not (pair key (big_map address nat))", c.contract)
+	}
+	c.teasId = storage.Args[1].Int
+	return nil
+}
+
+// xtzBatchParameter is the binary and JSON form of the list (pair address nat) for batchPayout
+func xtzBatchParameter(addresses []string, teas []*big.Int) ([]byte, []interface{}, error) {
+	var seq []byte
+	var js []interface{}
+	for i, a := range addresses {
+		acc, err := michelineAddress(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		seq = append(seq, michelinePair(acc, michelineNat(teas[i]))...)
+		id, _ := xtzContractId(a)
+		js = append(js, map[string]interface{}{
+			"prim": "Pair",
+			"args": []interface{}{map[string]string{"bytes": hex.EncodeToString(id)}, map[string]string{"int": teas[i].String()}},
+		})
+	}
+	out := []byte{0x02, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[1:], uint32(len(seq)))
+	return append(out, seq...), js, nil
+}
+
+// xtzTransaction is a call of an entrypoint of a contract, with Amount in mutez
+type xtzTransaction struct {
+	Source       string
+	Fee          int64
+	Counter      *big.Int
+	GasLimit     int64
+	StorageLimit int64
+	Amount       int64
+	Destination  string
+	Entrypoint   string
+	Parameter    []byte
+	ParameterJs  interface{}
+}
+
+func (t *xtzTransaction) json() map[string]interface{} {
+	return map[string]interface{}{
+		"kind":          "transaction",
+		"source":        t.Source,
+		"fee":           strconv.FormatInt(t.Fee, 10),
+		"counter":       t.Counter.String(),
+		"gas_limit":     strconv.FormatInt(t.GasLimit, 10),
+		"storage_limit": strconv.FormatInt(t.StorageLimit, 10),
+		"amount":        strconv.FormatInt(t.Amount, 10),
+		"destination":   t.Destination,
+		"parameters":    map[string]interface{}{"entrypoint": t.Entrypoint, "value": t.ParameterJs},
+	}
+}
+
+func (t *xtzTransaction) forge(branch string) ([]byte, error) {
+	b, err := base58.CheckDecode(branch)
+	if err != nil || !bytes.HasPrefix(b, xtzPrefixBlock) {
+		return nil, fmt.Errorf("invalid branch %v", branch)
+	}
+	src, err := xtzContractId(t.Source)
+	if err != nil || src[0] != 0 {
+		return nil, fmt.Errorf("invalid source %v", t.Source)
+	}
+	dst, err := xtzContractId(t.Destination)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, b[len(xtzPrefixBlock):]...)
+	out = append(out, xtzTagTransaction)
+	out = append(out, src[1:]...)
+	out = append(out, zarith(big.NewInt(t.Fee))...)
+	out = append(out, zarith(t.Counter)...)
+	out = append(out, zarith(big.NewInt(t.GasLimit))...)
+	out = append(out, zarith(big.NewInt(t.StorageLimit))...)
+	out = append(out, zarith(big.NewInt(t.Amount))...)
+	out = append(out, dst...)
+	//the parameters follow, default has its own tag, the other entrypoints are named
+	if t.Entrypoint == xtzDefaultEntrypoint {
+		out = append(out, 0xff, 0x00)
+	} else {
+		out = append(out, 0xff, 0xff, byte(len(t.Entrypoint)))
+		out = append(out, t.Entrypoint...)
+	}
+	l := make([]byte, 4)
+	binary.BigEndian.PutUint32(l, uint32(len(t.Parameter)))
+	out = append(out, l...)
+	return append(out, t.Parameter...), nil
+}
+
+func getXtzClient(url string, privateKey string, contract string, deploy bool) (*ClientXTZ, error) {
+	key, err := parseXtzKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &ClientXTZ{url: strings.TrimRight(url, "/"), c: &http.Client{Timeout: 30 * time.Second}, key: key, contract: contract}
+	err = c.get("/chains/main/chain_id", &c.chainId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = xtzChainId(c.chainId); err != nil {
+		return nil, err
+	}
+	log.Printf("Tezos chain %v, owner %v", c.chainId, key.Address())
+	if deploy {
+		c.contract, err = c.deploy("./PayoutTezos.json")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.contract == "" {
+		return nil, errors.New("no Tezos contract")
+	}
+	if _, err = xtzContractId(c.contract); err != nil {
+		return nil, err
+	}
+	if err = c.readTeasId(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ClientXTZ) get(path string, result interface{}) error {
+	return c.call(http.MethodGet, path, nil, result)
+}
+
+func (c *ClientXTZ) post(path string, body interface{}, result interface{}) error {
+	return c.call(http.MethodPost, path, body, result)
+}
+
+func (c *ClientXTZ) call(method string, path string, body interface{}, result interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.url+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("Tezos RPC %v: %w", path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Tezos RPC %v: %w", path, errXtzNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Tezos RPC %v: %v %s", path, resp.StatusCode, b)
+	}
+	return json.Unmarshal(b, result)
+}
+
+type xtzOperationResult struct {
+	Status              string            `json:"status"`
+	ConsumedMilligas    string            `json:"consumed_milligas"`
+	PaidStorageSizeDiff string            `json:"paid_storage_size_diff"`
+	OriginatedContracts []string          `json:"originated_contracts"`
+	Errors              []json.RawMessage `json:"errors"`
+}
+
+type xtzOperation struct {
+	Hash     string `json:"hash"`
+	Contents []struct {
+		Metadata struct {
+			OperationResult xtzOperationResult `json:"operation_result"`
+		} `json:"metadata"`
+	} `json:"contents"`
+}
+
+// XtzPayoutResult is the included operation, OriginatedContracts are the addresses of an origination
+type XtzPayoutResult struct {
+	OpHash              string            `json:"opHash"`
+	Level               int64             `json:"level"`
+	Status              string            `json:"status"`
+	Errors              []json.RawMessage `json:"errors,omitempty"`
+	OriginatedContracts []string          `json:"originatedContracts,omitempty"`
+}
+
+// simulate runs the operation and returns its result, the limits are set to the maximum of the protocol
+func (c *ClientXTZ) simulate(branch string, content map[string]interface{}) (*xtzOperationResult, error) {
+	var constants struct {
+		HardGasLimitPerOperation     string `json:"hard_gas_limit_per_operation"`
+		HardStorageLimitPerOperation string `json:"hard_storage_limit_per_operation"`
+	}
+	err := c.get("/chains/main/blocks/head/context/constants", &constants)
+	if err != nil {
+		return nil, err
+	}
+	content["gas_limit"] = constants.HardGasLimitPerOperation
+	content["storage_limit"] = constants.HardStorageLimitPerOperation
+	body := map[string]interface{}{
+		"operation": map[string]interface{}{"branch": branch, "contents": []interface{}{content}, "signature": xtzDummySignature},
+		"chain_id":  c.chainId,
+	}
+	var op xtzOperation
+	err = c.post("/chains/main/blocks/head/helpers/scripts/run_operation", body, &op)
+	if err != nil {
+		return nil, err
+	}
+	if len(op.Contents) != 1 {
+		return nil, errors.New("unexpected simulation result")
+	}
+	r := op.Contents[0].Metadata.OperationResult
+	if r.Status != "applied" {
+		return nil, fmt.Errorf("simulation %v: %s", r.Status, r.Errors)
+	}
+	return &r, nil
+}
+
+// limits sets the gas and storage limits from the simulation, with some margin
+func xtzLimits(r *xtzOperationResult, extraStorage int64) (int64, int64) {
+	milligas, _ := strconv.ParseInt(r.ConsumedMilligas, 10, 64)
+	storage, _ := strconv.ParseInt(r.PaidStorageSizeDiff, 10, 64)
+	return milligas/1000 + 100, storage + extraStorage + 20
+}
+
+// xtzFee is the minimal fee of the bakers, 100 mutez + 0.1 mutez per gas unit + 1 mutez per byte, with margin
+func xtzFee(gasLimit int64, size int) int64 {
+	return 100 + (gasLimit+9)/10 + int64(size) + 10
+}
+
+func (c *ClientXTZ) counter() (*big.Int, error) {
+	var counter string
+	err := c.get("/chains/main/blocks/head/context/contracts/"+c.key.Address()+"/counter", &counter)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := new(big.Int).SetString(counter, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid counter %v", counter)
+	}
+	return n.Add(n, big.NewInt(1)), nil
+}
+
+func (c *ClientXTZ) checkRevealed() error {
+	var managerKey *string
+	err := c.get("/chains/main/blocks/head/context/contracts/"+c.key.Address()+"/manager_key", &managerKey)
+	if err != nil {
+		return err
+	}
+	if managerKey == nil {
+		return fmt.Errorf("the public key of %v is not revealed", c.key.Address())
+	}
+	return nil
+}
+
+// batchPayout calls batchPayout of the contract and waits until it is included
+func (c *ClientXTZ) batchPayout(addresses []string, teas []*big.Int) (*XtzPayoutResult, error) {
+	param, paramJs, err := xtzBatchParameter(addresses, teas)
+	if err != nil {
+		return nil, err
+	}
+	return c.transact(&xtzTransaction{
+		Destination: c.contract,
+		Entrypoint:  xtzBatchEntrypoint,
+		Parameter:   param,
+		ParameterJs: paramJs,
+	})
+}
+
+// transact sends the transaction from the key and waits until it is included. Source, counter, limits and fee are
+// set here.
+func (c *ClientXTZ) transact(t *xtzTransaction) (*XtzPayoutResult, error) {
+	err := c.checkRevealed()
+	if err != nil {
+		return nil, err
+	}
+	var branch string
+	err = c.get("/chains/main/blocks/head/hash", &branch)
+	if err != nil {
+		return nil, err
+	}
+	t.Source = c.key.Address()
+	t.Counter, err = c.counter()
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.simulate(branch, t.json())
+	if err != nil {
+		return nil, err
+	}
+	t.GasLimit, t.StorageLimit = xtzLimits(r, 0)
+	forged, err := t.forge(branch)
+	if err != nil {
+		return nil, err
+	}
+	//the fee changes the size by a few bytes only, covered by the margin
+	t.Fee = xtzFee(t.GasLimit, len(forged)+64)
+	forged, err = t.forge(branch)
+	if err != nil {
+		return nil, err
+	}
+	opHash, err := c.inject(forged)
+	if err != nil {
+		return nil, err
+	}
+	return c.wait(opHash)
+}
+
+// inject signs the forged operation and sends it
+func (c *ClientXTZ) inject(forged []byte) (string, error) {
+	sig, err := c.key.sign(append([]byte{xtzWatermarkOp}, forged...))
+	if err != nil {
+		return "", err
+	}
+	signed := append(forged, sig...)
+	h := blake2b.Sum256(signed)
+	expected := base58.CheckEncode(append(append([]byte{}, xtzPrefixOp...), h[:]...))
+	var opHash string
+	err = c.post("/injection/operation", hex.EncodeToString(signed), &opHash)
+	if err != nil {
+		return "", err
+	}
+	if opHash != expected {
+		return "", fmt.Errorf("injected %v, expected %v", opHash, expected)
+	}
+	return opHash, nil
+}
+
+// wait polls the new blocks until the operation is included
+func (c *ClientXTZ) wait(opHash string) (*XtzPayoutResult, error) {
+	var level int64
+	var head struct {
+		Level int64 `json:"level"`
+	}
+	deadline := time.Now().Add(xtzPollTimeout)
+	for time.Now().Before(deadline) {
+		err := c.get("/chains/main/blocks/head/header", &head)
+		if err != nil {
+			return nil, err
+		}
+		if level == 0 {
+			level = head.Level
+		}
+		for ; level <= head.Level; level++ {
+			var ops []xtzOperation
+			err = c.get("/chains/main/blocks/"+strconv.FormatInt(level, 10)+"/operations/3", &ops)
+			if err != nil {
+				return nil, err
+			}
+			for _, op := range ops {
+				if op.Hash != opHash {
+					continue
+				}
+				res := &XtzPayoutResult{OpHash: opHash, Level: level}
+				for _, ct := range op.Contents {
+					r := ct.Metadata.OperationResult
+					res.Status = r.Status
+					res.Errors = append(res.Errors, r.Errors...)
+					res.OriginatedContracts = append(res.OriginatedContracts, r.OriginatedContracts...)
+				}
+				return res, nil
+			}
+		}
+		time.Sleep(xtzPollInterval)
+	}
+	return nil, fmt.Errorf("operation %v not included within %v", opHash, xtzPollTimeout)
+}
+
+// deploy originates the contract of the file with the Micheline JSON {"code": ...}, PayoutTezos.tz compiled, with
+// the key as owner. The origination is forged by the node, as it contains the arbitrary Micheline of the contract.
+func (c *ClientXTZ) deploy(filename string) (string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("can't read Tezos contract: %w", err)
+	}
+	var script struct {
+		Code    json.RawMessage `json:"code"`
+		Storage interface{}     `json:"storage"`
+	}
+	err = json.Unmarshal(b, &script)
+	if err != nil {
+		return "", fmt.Errorf("can't parse Tezos contract: %w", err)
+	}
+	//Pair owner teas, no account is payed out yet
+	script.Storage = map[string]interface{}{
+		"prim": "Pair",
+		"args": []interface{}{map[string]string{"string": c.key.PublicKey()}, []interface{}{}},
+	}
+	err = c.checkRevealed()
+	if err != nil {
+		return "", err
+	}
+	var branch string
+	err = c.get("/chains/main/blocks/head/hash", &branch)
+	if err != nil {
+		return "", err
+	}
+	counter, err := c.counter()
+	if err != nil {
+		return "", err
+	}
+	content := map[string]interface{}{
+		"kind":    "origination",
+		"source":  c.key.Address(),
+		"fee":     "0",
+		"counter": counter.String(),
+		"balance": "0",
+		"script":  script,
+	}
+	r, err := c.simulate(branch, content)
+	if err != nil {
+		return "", err
+	}
+	//the storage of the new contract is paid as well
+	gasLimit, storageLimit := xtzLimits(r, 257)
+	content["gas_limit"] = strconv.FormatInt(gasLimit, 10)
+	content["storage_limit"] = strconv.FormatInt(storageLimit, 10)
+	content["fee"] = strconv.FormatInt(xtzFee(gasLimit, len(b)+200), 10)
+	var forgedHex string
+	err = c.post("/chains/main/blocks/head/helpers/forge/operations", map[string]interface{}{
+		"branch": branch, "contents": []interface{}{content},
+	}, &forgedHex)
+	if err != nil {
+		return "", err
+	}
+	forged, err := hex.DecodeString(forgedHex)
+	if err != nil {
+		return "", err
+	}
+	opHash, err := c.inject(forged)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.wait(opHash)
+	if err != nil {
+		return "", err
+	}
+	if res.Status != "applied" {
+		return "", fmt.Errorf("origination %v: %v %s", opHash, res.Status, res.Errors)
+	}
+	//the address depends on the hash of the included operation, the one of the simulation differs
+	if len(res.OriginatedContracts) != 1 {
+		return "", fmt.Errorf("origination %v: %v originated contracts", opHash, len(res.OriginatedContracts))
+	}
+	fmt.Println("---------------------------------")
+	fmt.Println("Tezos operation: " + opHash)
+	fmt.Println("Tezos smart contract deployed: " + res.OriginatedContracts[0])
+	fmt.Println("---------------------------------")
+	return res.OriginatedContracts[0], nil
+}
+
+type xtzChain struct {
+	c *ClientXTZ
+}
+
+func newXtzChain(_ *Opts) (PayoutChain, error) {
+	xtzClient = xtzInit()
+	if xtzClient == nil {
+		return nil, errors.New("Tezos network is not initialized")
+	}
+	return &xtzChain{c: xtzClient}, nil
+}
+
+func (x *xtzChain) Name() string {
+	return "xtz"
+}
+
+func (x *xtzChain) Config() ChainConfig {
+	return ChainConfig{
+		Name:     x.Name(),
+		Contract: x.c.contract,
+		Network:  x.c.chainId,
+	}
+}
+
+// SignClaim returns the signature of the permit for withdraw, it may return a PendingApproval
+func (x *xtzChain) SignClaim(c Claim, subject string) (interface{}, error) {
+	sig, err := x.c.signWithdrawAndRecord(c.Address, c.Amount, subject, false)
+	if isApprovalRequired(err) {
+		a, err := createAccountApproval(x.Name(), c.Address, c.Amount, subject, err.Error())
+		if err != nil {
+			return nil, fmt.Errorf("could not create approval: %w", err)
+		}
+		return &PendingApproval{ApprovalId: a.Id, Status: a.Status, Rule: RuleApprovalThreshold}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// PaidOut reads the payed out tea of the address from the teas big_map of the contract
+func (x *xtzChain) PaidOut(c Claim) (*big.Int, error) {
+	if _, err := xtzContractId(c.Address); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	return x.c.paidOut(c.Address)
+}
+
+func (x *xtzChain) BatchPayout(addresses []string, amounts []*big.Int) (interface{}, error) {
+	for i, a := range addresses {
+		if _, err := xtzContractId(a); err != nil {
+			return nil, fmt.Errorf("%w: %v at %v", errInvalidRequest, err, i)
+		}
+	}
+	return x.c.batchPayout(addresses, amounts)
+}
+
+func (x *xtzChain) Time() (time.Time, error) {
+	var header struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	err := x.c.get("/chains/main/blocks/head/header", &header)
+	return header.Timestamp.UTC(), err
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// The golden vectors use the flextesa alice key. They were generated with tzgo (blockwatch.cc/tzgo v1.17.0), whose
+// encoding follows octez-client hash data and octez-codec, octez-client itself was not run.
+const (
+	xtzTestAlice    = "edsk3QoqBuvdamxouPhin7swCvkQNgq4jP5KZPbwWNnwdZpSpJiEbq"
+	xtzTestBob      = "tz1aSkwEot3L2kmUvcoxzjMomb9mvBNuzFK6"
+	xtzTestContract = "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn"
+	xtzTestChain    = "NetXdQprcVkpaWU"
+	xtzTestBranch   = "BLockGenesisGenesisGenesisGenesisGenesisf79b5d1CoW2"
+)
+
+func TestXtzKey(t *testing.T) {
+	k, err := parseXtzKey(xtzTestAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := k.Address(); a != "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb" {
+		t.Fatalf("address %v", a)
+	}
+	if p := k.PublicKey(); p != "edpkvGfYw3LyB1UcCahKQk4rF2tvbMUk8GFiTuMjL75uGXrpvKXhjn" {
+		t.Fatalf("public key %v", p)
+	}
+}
+
+func TestXtzContractId(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb": "00006b82198cb179e8306c1bedd08f12dc863f328886",
+		"tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya": "00010202020202020202020202020202020202020202",
+		"tz3LbyFVstLqf6Z1FUES6XWntR2bMmMVjBFL": "00020303030303030303030303030303030303030303",
+		xtzTestContract:                        "01a3d0f58d8964bd1b37fb0a0c197b38cf46608d4900",
+	} {
+		id, err := xtzContractId(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(id) != expected {
+			t.Errorf("%v is %x, expected %v", addr, id, expected)
+		}
+	}
+	for _, addr := range []string{"", "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjc", xtzTestChain} {
+		if _, err := xtzContractId(addr); err == nil {
+			t.Errorf("%v is not an address", addr)
+		}
+	}
+}
+
+func TestMichelineNat(t *testing.T) {
+	for n, expected := range map[string]string{
+		"0":                              "0000",
+		"1":                              "0001",
+		"63":                             "003f",
+		"64":                             "008001",
+		"1000000":                        "0080897a",
+		"4611686018427387904":            "0080808080808080808001",
+		"123456789012345678901234567890": "0092abf8e3c9bbf0f386dbff90dd63",
+	} {
+		v, _ := new(big.Int).SetString(n, 10)
+		if b := hex.EncodeToString(michelineNat(v)); b != expected {
+			t.Errorf("%v is %v, expected %v", n, b, expected)
+		}
+	}
+}
+
+func TestXtzPermit(t *testing.T) {
+	permit, err := xtzPermit(xtzTestChain, xtzTestContract, "tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya", big.NewInt(1500000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "05070707070a000000047a06a7700a0000001601a3d0f58d8964bd1b37fb0a0c197b38cf46608d490007070a0000001600" +
+		"01020202020202020202020202020202020202020200a08db701"
+	if hex.EncodeToString(permit) != expected {
+		t.Fatalf("permit is %x, expected %v", permit, expected)
+	}
+
+	k, err := parseXtzKey(xtzTestAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ClientXTZ{key: k, contract: xtzTestContract, chainId: xtzTestChain}
+	sig, packed, err := c.signWithdraw("tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya", big.NewInt(1500000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packed, permit) {
+		t.Fatalf("signed %x, expected the permit", packed)
+	}
+	if sig != "edsigtzZsZyktZo62HFMR56CaX7xTqAxsp7ttadKhEFZp7T3sbo8CCNMJGD5Fi65dUgKUi4HPP53aF44mMWvu113JvvHADqLaPp" {
+		t.Fatalf("signature %v", sig)
+	}
+
+	//another chain or contract gives another permit
+	other, err := xtzPermit("NetXnHfVqm9iesp", xtzTestContract, "tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya", big.NewInt(1500000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other, permit) {
+		t.Fatal("permit does not depend on the chain")
+	}
+}
+
+func TestXtzScriptExprHash(t *testing.T) {
+	for addr, expected := range map[string]string{
+		xtzTestBob:      "exprucjvbusVY8LH64yEX1PLB7RCtpbYbMG36g9XkKH3zge2CzGEQk",
+		xtzTestContract: "exprvAHu1SyoiSzyh9w7GPfifvyrNiMb442y7Q2MA8tcPCGPajxRH6",
+	} {
+		h, err := xtzScriptExprHash(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != expected {
+			t.Errorf("%v is %v, expected %v", addr, h, expected)
+		}
+	}
+}
+
+func TestXtzPaidOut(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chains/main/blocks/head/context/contracts/" + xtzTestContract + "/storage":
+			w.Write([]byte(`{"prim":"Pair","args":[{"string":"edpkvGfYw3LyB1UcCahKQk4rF2tvbMUk8GFiTuMjL75uGXrpvKXhjn"},{"int":"42"}]}`))
+		case "/chains/main/blocks/head/context/big_maps/42/exprucjvbusVY8LH64yEX1PLB7RCtpbYbMG36g9XkKH3zge2CzGEQk":
+			w.Write([]byte(`{"int":"1500"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &ClientXTZ{url: srv.URL, c: srv.Client(), contract: xtzTestContract}
+	if err := c.readTeasId(); err != nil || c.teasId != "42" {
+		t.Fatalf("teas big_map %v: %v", c.teasId, err)
+	}
+	paid, err := c.paidOut(xtzTestBob)
+	if err != nil || paid.Int64() != 1500 {
+		t.Fatalf("payed out %v: %v", paid, err)
+	}
+	//an account without an entry was not payed out yet
+	paid, err = c.paidOut("tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya")
+	if err != nil || paid.Sign() != 0 {
+		t.Fatalf("payed out %v: %v", paid, err)
+	}
+}
+
+func TestXtzForge(t *testing.T) {
+	param, _, err := xtzBatchParameter(
+		[]string{"tz28VrRE9HABQKiEDExZySi4a79Pfeusu4Ya", xtzTestContract},
+		[]*big.Int{big.NewInt(10), big.NewInt(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &xtzTransaction{
+		Source:       "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+		Fee:          1234,
+		Counter:      big.NewInt(56789),
+		GasLimit:     10400,
+		StorageLimit: 300,
+		Destination:  xtzTestContract,
+		Entrypoint:   xtzBatchEntrypoint,
+		Parameter:    param,
+	}
+	forged, err := tx.forge(xtzTestBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "8fcf233671b6a04fcf679d2a381c2544ea6c1ea29ba6157776ed8424c7ccd00b6c006b82198cb179e8306c1bedd08f12dc8" +
+		"63f328886d209d5bb03a051ac020001a3d0f58d8964bd1b37fb0a0c197b38cf46608d4900ffff0b62617463685061796f75740000" +
+		"0044020000003f07070a0000001600010202020202020202020202020202020202020202000a07070a0000001601a3d0f58d8964" +
+		"bd1b37fb0a0c197b38cf46608d490000a80f"
+	if hex.EncodeToString(forged) != expected {
+		t.Fatalf("batchPayout is %x, expected %v", forged, expected)
+	}
+
+	tx = &xtzTransaction{
+		Source:      "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+		Fee:         500,
+		Counter:     big.NewInt(7),
+		GasLimit:    1600,
+		Amount:      2000000,
+		Destination: xtzTestContract,
+		Entrypoint:  xtzDefaultEntrypoint,
+		Parameter:   []byte{0x03, 0x0b},
+	}
+	forged, err = tx.forge(xtzTestBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "8fcf233671b6a04fcf679d2a381c2544ea6c1ea29ba6157776ed8424c7ccd00b6c006b82198cb179e8306c1bedd08f12dc8" +
+		"63f328886f40307c00c0080897a01a3d0f58d8964bd1b37fb0a0c197b38cf46608d4900ff0000000002030b"
+	if hex.EncodeToString(forged) != expected {
+		t.Fatalf("default is %x, expected %v", forged, expected)
+	}
+}
+
+func xtzBalance(t *testing.T, c *ClientXTZ, addr string) int64 {
+	t.Helper()
+	var balance string
+	if err := c.get("/chains/main/blocks/head/context/contracts/"+addr+"/balance", &balance); err != nil {
+		t.Fatal(err)
+	}
+	b, err := strconv.ParseInt(balance, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// withdrawTx calls withdraw of the contract with the permit of the owner
+func withdrawTx(c *ClientXTZ, account string, tea *big.Int) (*xtzTransaction, error) {
+	permit, err := xtzPermit(c.chainId, c.contract, account, tea)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := c.key.sign(permit)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := michelineAddress(account)
+	if err != nil {
+		return nil, err
+	}
+	return &xtzTransaction{
+		Destination: c.contract,
+		Entrypoint:  "withdraw",
+		Parameter:   michelinePair(acc, michelinePair(michelineNat(tea), michelineBytes(sig))),
+		ParameterJs: map[string]interface{}{"prim": "Pair", "args": []interface{}{
+			map[string]string{"string": account},
+			map[string]interface{}{"prim": "Pair", "args": []interface{}{
+				map[string]string{"int": tea.String()}, map[string]string{"string": c.key.encodeSignature(sig)},
+			}},
+		}},
+	}, nil
+}
+
+// TestXtzSandbox originates PayoutTezos on a flextesa sandbox, e.g., started with
+// docker run --rm -p 20000:20000 oxheadalpha/flextesa:latest nairobibox start
+// and XTZ_SANDBOX_URL=http://localhost:20000. alice is the owner, bob is payed out.
+func TestXtzSandbox(t *testing.T) {
+	url := os.Getenv("XTZ_SANDBOX_URL")
+	if url == "" {
+		t.Skip("XTZ_SANDBOX_URL is not set")
+	}
+	c, err := getXtzClient(url, xtzTestAlice, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.transact(&xtzTransaction{
+		Amount:      2_000_000,
+		Destination: c.contract,
+		Entrypoint:  xtzDefaultEntrypoint,
+		Parameter:   []byte{0x03, 0x0b},
+		ParameterJs: map[string]string{"prim": "Unit"},
+	})
+	if err != nil || res.Status != "applied" {
+		t.Fatalf("funding the contract: %+v %v", res, err)
+	}
+
+	before := xtzBalance(t, c, xtzTestBob)
+	res, err = c.batchPayout([]string{xtzTestBob}, []*big.Int{big.NewInt(1000)})
+	if err != nil || res.Status != "applied" {
+		t.Fatalf("batchPayout: %+v %v", res, err)
+	}
+	if b := xtzBalance(t, c, xtzTestBob); b-before != 1000 {
+		t.Fatalf("bob received %v mutez by batchPayout, expected 1000", b-before)
+	}
+
+	//only the difference to the payed out tea is sent
+	tx, err := withdrawTx(c, xtzTestBob, big.NewInt(1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = c.transact(tx)
+	if err != nil || res.Status != "applied" {
+		t.Fatalf("withdraw: %+v %v", res, err)
+	}
+	if b := xtzBalance(t, c, xtzTestBob); b-before != 1500 {
+		t.Fatalf("bob received %v mutez in total, expected 1500", b-before)
+	}
+	if paid, err := c.paidOut(xtzTestBob); err != nil || paid.Int64() != 1500 {
+		t.Fatalf("payed out %v to bob: %v", paid, err)
+	}
+
+	//the permit cannot be used again
+	tx, err = withdrawTx(c, xtzTestBob, big.NewInt(1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.transact(tx); err == nil {
+		t.Fatal("withdraw with the same permit succeeded")
+	}
+}